@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kjloveless/tmp/internal/metadata"
+	"github.com/kjloveless/tmp/internal/playlist"
+	"github.com/kjloveless/tmp/internal/theme"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = ""
+
+func versionString() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+const usageHeader = `Usage: tmp [flags] [directory] [file or playlist ...]
+
+Browses directory and plays the given audio files and .m3u/.m3u8/.pls
+playlists straight away, in order. Without a directory it reopens the one
+browsed in the last session, or the current directory if there is none.
+
+Flags:
+`
+
+type options struct {
+	dir         string
+	paths       []string
+	volume      int // -1 keeps the saved volume
+	loop        string
+	shuffle     bool
+	version     bool
+	configPath  string
+	printConfig bool
+	theme       string
+	// seekSmall and seekLarge override the configured seek steps; zero
+	// keeps them.
+	seekSmall time.Duration
+	seekLarge time.Duration
+}
+
+// parseArgs reads the command line. Flags may come before or after the
+// paths. --help prints usage to stdout and returns flag.ErrHelp.
+func parseArgs(args []string, stdout io.Writer) (options, error) {
+	opts := options{volume: -1}
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	// The upper bound depends on the config and is checked once it is
+	// loaded.
+	fs.Func("volume", "initial volume in `percent`, up to playback.max_volume", func(value string) error {
+		volume, err := strconv.Atoi(value)
+		if err != nil || volume < 0 {
+			return errors.New("must be a whole number of percent")
+		}
+		opts.volume = volume
+		return nil
+	})
+	fs.StringVar(&opts.loop, "loop", "", "loop `mode`: off, current or queue")
+	fs.BoolVar(&opts.shuffle, "shuffle", false, "shuffle the queue")
+	fs.BoolVar(&opts.version, "version", false, "print the version and exit")
+	fs.StringVar(&opts.configPath, "config", "", "read settings from `file` instead of $XDG_CONFIG_HOME/tmp/config.toml")
+	fs.BoolVar(&opts.printConfig, "print-config", false, "print the effective settings as TOML and exit")
+	fs.Func("seek-step", "small seek `step`, e.g. 10s (playback.seek_step)", seekStepFlag(&opts.seekSmall))
+	fs.Func("large-seek-step", "large seek `step`, e.g. 2m (playback.large_seek_step)", seekStepFlag(&opts.seekLarge))
+	fs.StringVar(&opts.theme, "theme", "", "color `theme`: "+strings.Join(theme.Names(), ", ")+" or a theme file")
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				fmt.Fprint(stdout, usageHeader)
+				fs.SetOutput(stdout)
+				fs.PrintDefaults()
+			}
+			return options{}, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		// Everything after -- is a path, even if it starts with a dash.
+		if fs.NArg() < len(args) && args[len(args)-fs.NArg()-1] == "--" {
+			positional = append(positional, fs.Args()...)
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if _, ok := loopModeByName(opts.loop); opts.loop != "" && !ok {
+		return options{}, fmt.Errorf("--loop %q: must be off, current or queue", opts.loop)
+	}
+
+	for _, arg := range positional {
+		path, err := filepath.Abs(arg)
+		if err != nil {
+			return options{}, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return options{}, err
+		}
+		switch {
+		case info.IsDir():
+			if opts.dir != "" {
+				return options{}, fmt.Errorf("%s: only one directory can be browsed", arg)
+			}
+			opts.dir = path
+		case isSupportedAudioFile(path) || playlist.Supported(path):
+			opts.paths = append(opts.paths, path)
+		default:
+			return options{}, fmt.Errorf("%s: not a supported audio file or playlist", arg)
+		}
+	}
+	return opts, nil
+}
+
+func seekStepFlag(step *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return errors.New("must be a positive duration like 10s or 1m30s")
+		}
+		*step = d
+		return nil
+	}
+}
+
+// startTracks expands the file arguments into queue entries, reporting
+// playlist entries that could not be found to warn.
+func startTracks(paths []string, warn io.Writer) ([]queuedTrack, error) {
+	var tracks []queuedTrack
+	for _, path := range paths {
+		if playlist.Supported(path) {
+			msg := loadPlaylistCmd(path)().(playlistLoadedMsg)
+			if msg.err != nil {
+				return nil, msg.err
+			}
+			if msg.missing > 0 {
+				fmt.Fprintf(warn, "%s: skipped %d missing entries\n", filepath.Base(path), msg.missing)
+			}
+			tracks = append(tracks, msg.tracks...)
+			continue
+		}
+		tags, _ := metadata.Read(path)
+		tracks = append(tracks, queuedTrack{path: path, title: tags.DisplayTitle(filepath.Base(path)), tags: tags})
+	}
+	return tracks, nil
+}
+
+// applyOptions lets the command line override the restored session. Tracks
+// given on the command line go ahead of the restored queue and the first one
+// starts instead of resuming the last session's track.
+func (m *model) applyOptions(opts options, tracks []queuedTrack) {
+	if opts.dir != "" {
+		m.tracks.picker.CurrentDirectory = opts.dir
+	}
+	if opts.volume >= 0 {
+		m.volume = opts.volume
+	}
+	if mode, ok := loopModeByName(opts.loop); ok {
+		m.loopMode = mode
+	}
+	if len(tracks) > 0 {
+		m.queue = slices.Insert(m.queue, 0, tracks...)
+		m.resumePath, m.resumeAt = "", 0
+	}
+	if opts.shuffle && !m.shuffle {
+		m.toggleShuffle()
+	}
+	if len(tracks) > 0 {
+		next, _ := m.dequeueNext()
+		m.startPath = next.path
+	}
+}
+
+func loopModeByName(name string) (loopMode, bool) {
+	for _, mode := range []loopMode{loopOff, loopCurrent, loopQueue} {
+		if strings.EqualFold(mode.String(), name) {
+			return mode, true
+		}
+	}
+	return loopOff, false
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gopxl/beep/v2"
+
+	"github.com/kjloveless/tmp/internal/config"
+	"github.com/kjloveless/tmp/internal/help"
+	"github.com/kjloveless/tmp/internal/theme"
+)
+
+// defaultConfig is the compiled-in configuration a config file overrides.
+func defaultConfig() config.Config {
+	return config.Config{
+		Theme: theme.Names()[0],
+		Playback: config.Playback{
+			SeekStep:      seekStep,
+			LargeSeekStep: largeSeekStep,
+			VolumeStep:    volumeStep,
+			MaxVolume:     maxVolumePercent,
+			SampleRate:    outputSampleRate,
+			Buffer:        speakerBuffer,
+		},
+		Spectrum: config.Spectrum{
+			FFTSize: spectrumFFTSize,
+			FloorDB: spectrumFloorDB,
+		},
+	}
+}
+
+// loadConfig reads the config file named on the command line, or the one at
+// the default location if it exists.
+func loadConfig(path string) (config.Config, error) {
+	required := path != ""
+	if !required {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return defaultConfig(), nil
+		}
+	}
+	return config.Load(path, defaultConfig(), required)
+}
+
+// keyMap applies the configured key overrides to the default bindings.
+func keyMap(cfg config.Config) (help.KeyMap, error) {
+	keys := help.DefaultKeyMap
+	for _, scope := range []struct {
+		name      string
+		overrides map[string]config.KeyList
+	}{
+		{"global", cfg.Keys.Global},
+		{"tracks", cfg.Keys.Tracks},
+		{"queue", cfg.Keys.Queue},
+	} {
+		overrides := make(map[string][]string, len(scope.overrides))
+		for action, list := range scope.overrides {
+			overrides[action] = list
+		}
+		var err error
+		if keys, err = keys.Remap(scope.name, overrides); err != nil {
+			return help.KeyMap{}, err
+		}
+	}
+	return keys, nil
+}
+
+// effectiveConfig fills in every key binding, so printing it documents the
+// action names as well as the keys in use.
+func (m model) effectiveConfig(cfg config.Config) config.Config {
+	scopes := []*map[string]config.KeyList{&cfg.Keys.Global, &cfg.Keys.Tracks, &cfg.Keys.Queue}
+	for i, name := range []string{"global", "tracks", "queue"} {
+		bindings := m.help.Keys().Bindings(name)
+		keys := make(map[string]config.KeyList, len(bindings))
+		for action, list := range bindings {
+			keys[action] = list
+		}
+		*scopes[i] = keys
+	}
+	return cfg
+}
+
+// applyConfig also makes the configured theme the active one, since the
+// style functions read it from there.
+func (m *model) applyConfig(cfg config.Config) error {
+	palette, err := theme.Resolve(cfg.Theme)
+	if err != nil {
+		return fmt.Errorf("theme: %w", err)
+	}
+	keys, err := keyMap(cfg)
+	if err != nil {
+		return err
+	}
+	hu, err := help.NewHelpUI(seekKeyMap(keys, cfg.Playback.SeekStep, cfg.Playback.LargeSeekStep))
+	if err != nil {
+		return err
+	}
+	theme.Use(palette)
+	m.help = hu
+	m.seekSmall = cfg.Playback.SeekStep
+	m.seekLarge = cfg.Playback.LargeSeekStep
+	m.volumeDelta = cfg.Playback.VolumeStep
+	m.volumeLimit = cfg.Playback.MaxVolume
+	m.volume = min(m.volume, m.volumeLimit)
+	m.sampleRate = beep.SampleRate(cfg.Playback.SampleRate)
+	m.meter = newAudioMeter(96, cfg.Spectrum.FFTSize, cfg.Spectrum.FloorDB)
+	return nil
+}
+
+func (m model) volumeStepPercent() int {
+	if m.volumeDelta <= 0 {
+		return volumeStep
+	}
+	return m.volumeDelta
+}
+
+func (m model) maxVolume() int {
+	if m.volumeLimit <= 0 {
+		return maxVolumePercent
+	}
+	return m.volumeLimit
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/kjloveless/tmp/internal/metadata"
+)
+
+type directoryScannedMsg struct {
+	dir    string
+	tracks []queuedTrack
+	err    error
+}
+
+// enqueueSelectedDirectoryCmd scans the highlighted directory in the
+// background; the tracks are queued once the scan arrives.
+func (m model) enqueueSelectedDirectoryCmd() tea.Cmd {
+	dir, ok := m.tracks.selectedDirectoryPath()
+	if !ok {
+		return nil
+	}
+	return func() tea.Msg {
+		tracks, err := scanDirectory(dir)
+		return directoryScannedMsg{dir: dir, tracks: tracks, err: err}
+	}
+}
+
+func (m *model) acceptDirectory(msg directoryScannedMsg) error {
+	if msg.err != nil {
+		return msg.err
+	}
+	queued := 0
+	for _, t := range msg.tracks {
+		if m.enqueueTrack(t.path, t.title, t.tags) {
+			queued++
+		}
+	}
+	m.notice = fmt.Sprintf("+%d from %s", queued, filepath.Base(msg.dir))
+	return nil
+}
+
+// scanDirectory lists the supported audio files below dir, directory by
+// directory in natural order, with each directory's files ordered by track
+// number and then by name.
+func scanDirectory(dir string) ([]queuedTrack, error) {
+	var tracks []queuedTrack
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable subdirectories rather than abandoning the scan.
+			if path != dir && entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() || !isSupportedAudioFile(path) {
+			return nil
+		}
+		tags, _ := metadata.Read(path)
+		tracks = append(tracks, queuedTrack{
+			path:  path,
+			title: tags.DisplayTitle(filepath.Base(path)),
+			tags:  tags,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTracks(tracks)
+	return tracks, nil
+}
+
+func sortTracks(tracks []queuedTrack) {
+	slices.SortStableFunc(tracks, func(a, b queuedTrack) int {
+		if c := naturalCompare(filepath.Dir(a.path), filepath.Dir(b.path)); c != 0 {
+			return c
+		}
+		if c := compareTrackNumbers(a.tags.Track, b.tags.Track); c != 0 {
+			return c
+		}
+		return naturalCompare(filepath.Base(a.path), filepath.Base(b.path))
+	})
+}
+
+// compareTrackNumbers orders numbered tracks first; untagged ones (0) follow.
+func compareTrackNumbers(a, b int) int {
+	switch {
+	case a == b:
+		return 0
+	case a == 0:
+		return 1
+	case b == 0:
+		return -1
+	case a < b:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// naturalCompare compares strings case-insensitively, treating runs of
+// digits as numbers so that "2 intro" sorts before "10 outro".
+func naturalCompare(a, b string) int {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, restA := splitDigits(a)
+			nb, restB := splitDigits(b)
+			trimmedA, trimmedB := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(trimmedA) != len(trimmedB) {
+				return compareInts(len(trimmedA), len(trimmedB))
+			}
+			if c := strings.Compare(trimmedA, trimmedB); c != 0 {
+				return c
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return compareInts(int(a[0]), int(b[0]))
+		}
+		a, b = a[1:], b[1:]
+	}
+	return compareInts(len(a), len(b))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func splitDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/gopxl/beep/v2"
+	"github.com/gopxl/beep/v2/speaker"
+
+	"github.com/kjloveless/tmp/internal/dsp"
+	"github.com/kjloveless/tmp/internal/theme"
+)
+
+const eqGainStep = 1.0 // dB
+
+// currentEqualizer returns the active settings, which start out flat.
+func (m model) currentEqualizer() dsp.Preset {
+	if len(m.equalizer.Bands) == 0 {
+		return dsp.Presets[0]
+	}
+	return m.equalizer
+}
+
+func (m *model) applyEqualizer() {
+	if m.eq == nil {
+		return
+	}
+	speaker.Lock()
+	m.eq.SetPreset(m.equalizer)
+	speaker.Unlock()
+}
+
+func (m *model) cycleEqualizer() {
+	m.eqPreset = (m.eqPreset + 1) % len(dsp.Presets)
+	m.equalizer = dsp.Presets[m.eqPreset].Clone()
+	m.applyEqualizer()
+}
+
+func (m *model) selectEqualizerBand(delta int) {
+	count := len(m.currentEqualizer().Bands)
+	m.eqBand = (m.eqBand + delta + count) % count
+}
+
+// adjustEqualizerBand nudges the selected band, turning the active preset
+// into a custom one.
+func (m *model) adjustEqualizerBand(delta float64) {
+	eq := m.currentEqualizer().Clone()
+	band := &eq.Bands[m.eqBand]
+	gain := max(-dsp.MaxBandGain, min(band.Gain+delta, dsp.MaxBandGain))
+	if gain == band.Gain {
+		return
+	}
+	band.Gain = gain
+	eq.Name = "custom"
+	m.equalizer = eq
+	m.applyEqualizer()
+}
+
+func (m model) equalizerLabel() string {
+	eq := m.currentEqualizer()
+	if eq.Flat() {
+		return ""
+	}
+	return fmt.Sprintf("eq %s", eq.Name)
+}
+
+func (m model) equalizerSampleRate() beep.SampleRate {
+	if m.sampleRate <= 0 {
+		return 48000
+	}
+	return m.sampleRate
+}
+
+func frequencyLabel(freq float64) string {
+	if freq >= 1000 {
+		return fmt.Sprintf("%gk", freq/1000)
+	}
+	return fmt.Sprintf("%g", freq)
+}
+
+// equalizerView takes the place of the sound map while the editor is open.
+// It draws the response curve over the live spectrum on the same frequency
+// axis, between -MaxBandGain at the bottom and +MaxBandGain at the top.
+func (m model) equalizerView(width, plotHeight int) string {
+	if width <= 0 || plotHeight <= 0 {
+		return ""
+	}
+
+	const labelWidth = 4
+	plotAreaWidth := boundedWidth(width - labelWidth - 1)
+	plotWidth := compactVisualizerWidth(plotAreaWidth)
+	if plotWidth < 12 {
+		plotWidth = plotAreaWidth
+	}
+	if plotWidth < 1 {
+		plotWidth = 1
+	}
+
+	eq := m.currentEqualizer()
+	sampleRate := m.equalizerSampleRate()
+	maxFreq := math.Min(spectrumMaxFreq, float64(sampleRate)/2)
+	selected := eq.Bands[min(m.eqBand, len(eq.Bands)-1)]
+
+	var levels []float64
+	if m.meter != nil {
+		levels = m.meter.Bins(plotWidth)
+	} else {
+		levels = make([]float64, plotWidth)
+	}
+
+	rowFor := func(db float64) int {
+		position := (dsp.MaxBandGain - db) / (2 * dsp.MaxBandGain)
+		return max(0, min(int(math.Round(position*float64(plotHeight-1))), plotHeight-1))
+	}
+	zeroRow := rowFor(0)
+	curve := make([]int, plotWidth)
+	markerColumn, markerDistance := 0, math.Inf(1)
+	for column := range curve {
+		freq := logarithmicFrequency((float64(column)+0.5)/float64(plotWidth), spectrumMinFreq, maxFreq)
+		curve[column] = rowFor(dsp.Response(eq, sampleRate, freq))
+		if distance := math.Abs(math.Log(freq / selected.Frequency)); distance < markerDistance {
+			markerColumn, markerDistance = column, distance
+		}
+	}
+
+	lines := make([]string, 0, plotHeight+3)
+	lines = append(lines, fmt.Sprintf("Equalizer • %s", eq.Name))
+	lines = append(lines, ansi.Truncate(fmt.Sprintf("▸ %s Hz %+.0f dB", frequencyLabel(selected.Frequency), selected.Gain), width, ""))
+	palette := theme.Active()
+	for row := 0; row < plotHeight; row++ {
+		var b strings.Builder
+		for column, level := range levels {
+			cell, color := " ", palette.Grid
+			switch {
+			case curve[column] == row:
+				cell, color = "●", palette.Focus
+			case row >= plotHeight-int(math.Round(level*float64(plotHeight))):
+				cell, color = "│", spectrogramColor(level)
+			case row == zeroRow:
+				cell = "┄"
+			}
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(cell))
+		}
+		label := "    "
+		switch row {
+		case 0:
+			label = fmt.Sprintf("%+3.0f ", dsp.MaxBandGain)
+		case zeroRow:
+			label = "  0 "
+		case plotHeight - 1:
+			label = fmt.Sprintf("%+3.0f ", -dsp.MaxBandGain)
+		}
+		line := label + " " + lipgloss.NewStyle().Width(plotAreaWidth).Align(lipgloss.Center).Render(b.String())
+		lines = append(lines, ansi.Truncate(line, width, ""))
+	}
+	marker := strings.Repeat(" ", markerColumn) + "▲" + strings.Repeat(" ", plotWidth-markerColumn-1)
+	line := strings.Repeat(" ", labelWidth+1) + lipgloss.NewStyle().Width(plotAreaWidth).Align(lipgloss.Center).Render(marker)
+	lines = append(lines, ansi.Truncate(line, width, ""))
+
+	return strings.Join(lines, "\n")
+}
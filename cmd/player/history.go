@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+const (
+	maxHistory = 100
+	// Previous restarts the playing track instead of going back once it has
+	// played for longer than this.
+	restartThreshold = 3 * time.Second
+)
+
+// pushHistory records the playing track as played.
+func (m *model) pushHistory() {
+	if m.playingPath == "" {
+		return
+	}
+	m.history = append(m.history, queuedTrack{
+		path:  m.playingPath,
+		title: m.playing.Title,
+		tags:  m.playing.Tags,
+	})
+	if len(m.history) > maxHistory {
+		m.history = m.history[len(m.history)-maxHistory:]
+	}
+	m.clampHistoryCursor()
+}
+
+func (m *model) clampHistoryCursor() {
+	m.historyCursor = max(0, min(m.historyCursor, len(m.history)-1))
+}
+
+func (m *model) moveHistoryCursor(delta int) {
+	m.historyCursor += delta
+	m.clampHistoryCursor()
+}
+
+// playPrevious restarts the playing track once it is a few seconds in, and
+// otherwise goes back to the last played track, putting the current one back
+// at the head of the queue so next returns to it.
+func (m *model) playPrevious() (tea.Cmd, error) {
+	if len(m.history) == 0 || (m.canSeek() && m.playing.Position() > restartThreshold) {
+		return m.seekTo(0)
+	}
+
+	last := m.history[len(m.history)-1]
+	m.history = m.history[:len(m.history)-1]
+	m.clampHistoryCursor()
+	if m.playingPath != "" {
+		m.enqueueNext(m.playingPath, m.playing.Title, m.playing.Tags)
+	}
+	cmd := m.playSongCmd(last.path)
+	return func() tea.Msg {
+		msg := cmd()
+		// The playing track went back to the head of the queue, so it must
+		// not also be recorded as played when this one loads.
+		if loaded, ok := msg.(loadedTrackMsg); ok {
+			loaded.back = true
+			return loaded
+		}
+		return msg
+	}, nil
+}
+
+// playNext skips to the head of the queue.
+func (m *model) playNext() (tea.Cmd, error) {
+	if !m.isPlaying() {
+		cmd, _ := m.playNextQueuedCmd()
+		return cmd, nil
+	}
+	return m.finishCurrentTrack()
+}
+
+// historyLines lists played tracks, most recent first.
+func (m model) historyLines(contentWidth int) (lines []string, selectedStart, selectedEnd int) {
+	lines = []string{fmt.Sprintf("History (%d)", len(m.history)), ""}
+	selectedStart, selectedEnd = -1, -1
+	if len(m.history) == 0 {
+		return append(lines, "  (nothing played yet)"), selectedStart, selectedEnd
+	}
+	for i := len(m.history) - 1; i >= 0; i-- {
+		prefix := "  "
+		selected := m.focus == focusQueue && i == m.historyCursor
+		if selected {
+			prefix = "› "
+		}
+		start, end := appendQueueBlock(&lines, wrapQueueItem(fmt.Sprintf("%s%d. ", prefix, len(m.history)-i), m.history[i].title, contentWidth))
+		if selected {
+			selectedStart, selectedEnd = start, end
+		}
+	}
+	return lines, selectedStart, selectedEnd
+}
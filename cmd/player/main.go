@@ -2,29 +2,43 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
 	"log"
 	"math"
 	"math/cmplx"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"charm.land/bubbles/v2/filepicker"
 	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/textinput"
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/colorprofile"
 	"github.com/charmbracelet/x/ansi"
+	"github.com/kjloveless/tmp/internal/artwork"
+	"github.com/kjloveless/tmp/internal/config"
+	"github.com/kjloveless/tmp/internal/decode"
+	"github.com/kjloveless/tmp/internal/dsp"
 	"github.com/kjloveless/tmp/internal/help"
+	"github.com/kjloveless/tmp/internal/loudness"
+	"github.com/kjloveless/tmp/internal/metadata"
+	"github.com/kjloveless/tmp/internal/playlist"
+	"github.com/kjloveless/tmp/internal/session"
+	"github.com/kjloveless/tmp/internal/theme"
 	"github.com/kjloveless/tmp/internal/track"
 
 	"github.com/gopxl/beep/v2"
 	"github.com/gopxl/beep/v2/effects"
-	"github.com/gopxl/beep/v2/mp3"
 	"github.com/gopxl/beep/v2/speaker"
-	"github.com/gopxl/beep/v2/wav"
 )
 
 const (
@@ -48,8 +62,16 @@ const (
 	spectrumMaxFreq        = 18000.0
 	spectrumFloorDB        = -72.0
 	seekStep               = 5 * time.Second
+	largeSeekStep          = time.Minute
 	volumeStep             = 10
 	maxVolumePercent       = 150
+	outputSampleRate       = 48000
+	speakerBuffer          = 100 * time.Millisecond
+	speedStep              = 10
+	minSpeedPercent        = 50
+	maxSpeedPercent        = 200
+	minArtworkStatusWidth  = 40
+	compactArtworkWidth    = 2
 )
 
 type focusMode int
@@ -103,14 +125,60 @@ type model struct {
 	height        int
 	volume        int
 	muted         bool
+	speed         int
+	preservePitch bool
+	seekSmall     time.Duration
+	seekLarge     time.Duration
+	prompt        textinput.Model
+	promptKind    promptKind
+	prompting     bool
+	savePath      string // playlist waiting for overwrite confirmation
+	lastClick     queueClick
+	shuffle       bool
+	passLeft      int
+	rng           *rand.Rand
+	history       []queuedTrack
+	historyCursor int
+	showHistory   bool
+	// notice reports the outcome of the last bulk action until the next
+	// key press.
+	notice        string
+	sessionPath   string // where state is saved; empty disables saving
+	sessionWriter *sessionWriter
+	sessionGen    uint64 // counts snapshots taken for saving
+	sessionSaved  time.Time
+	resumePath    string
+	resumeAt      time.Duration
+	volumeDelta   int    // percent; zero means the default
+	volumeLimit   int    // percent; zero means the default
+	startPath     string // first track given on the command line
 	transitioning bool
 	meter         *audioMeter
+	gapless       bool
+	crossfade     time.Duration
+	gainMode      gainMode
+	loudness      *loudness.Cache
+	prescanned    string // next track last measured ahead of its play
+	sequence      *trackSequence
+	fadingOut     track.Track
+	output        *effects.Volume
+	limiter       *dsp.Limiter
+	eq            *dsp.Equalizer
+	equalizer     dsp.Preset
+	eqPreset      int
+	eqEditor      bool
+	eqBand        int
+	preloaded     *loadedTrackMsg
+	preloading    string
+	artwork       image.Image
+	colorProfile  colorprofile.Profile
 	err           error
 }
 
 type queuedTrack struct {
 	path  string
 	title string
+	tags  metadata.Tags
 }
 
 type (
@@ -118,7 +186,14 @@ type (
 	loadedTrackMsg struct {
 		track    track.Track
 		path     string
+		artwork  image.Image
 		previous beep.StreamSeekCloser
+		// back marks a track reached through previous, which must not be
+		// pushed onto the history again.
+		back bool
+		// resume marks the track restored from the last session, which
+		// starts paused.
+		resume bool
 	}
 )
 
@@ -131,6 +206,8 @@ type audioMeter struct {
 	history    []float64
 	frames     [][]float64
 	sampleRate float64
+	fftSize    int
+	floorDB    float64
 }
 
 type namedBandLevel struct {
@@ -139,28 +216,24 @@ type namedBandLevel struct {
 }
 
 func supportedAudioExtensions() []string {
-	return []string{".mp3", ".wav"}
+	return decode.Extensions()
 }
 
 func isSupportedAudioFile(path string) bool {
-	lower := strings.ToLower(path)
-	for _, ext := range supportedAudioExtensions() {
-		if strings.HasSuffix(lower, ext) {
-			return true
-		}
-	}
-	return false
+	return decode.Supported(path)
 }
 
-func newAudioMeter(binCount int) *audioMeter {
+func newAudioMeter(binCount, fftSize int, floorDB float64) *audioMeter {
 	if binCount < 8 {
 		binCount = 8
 	}
 	return &audioMeter{
 		bins:       make([]float64, binCount),
-		history:    make([]float64, 0, spectrumFFTSize),
+		history:    make([]float64, 0, fftSize),
 		frames:     make([][]float64, 0, spectrumHistorySize),
 		sampleRate: 48000,
+		fftSize:    fftSize,
+		floorDB:    floorDB,
 	}
 }
 
@@ -196,11 +269,11 @@ func (m *audioMeter) Process(samples [][2]float64) {
 
 	m.mu.Lock()
 	m.history = append(m.history, mono...)
-	if len(m.history) > spectrumFFTSize {
-		m.history = append(m.history[:0], m.history[len(m.history)-spectrumFFTSize:]...)
+	if len(m.history) > m.fftSize {
+		m.history = append(m.history[:0], m.history[len(m.history)-m.fftSize:]...)
 	}
 
-	if len(m.history) < spectrumFFTSize {
+	if len(m.history) < m.fftSize {
 		m.mu.Unlock()
 		return
 	}
@@ -210,7 +283,7 @@ func (m *audioMeter) Process(samples [][2]float64) {
 	binCount := len(m.bins)
 	m.mu.Unlock()
 
-	spectrum := analyzeSpectrum(window, sampleRate, binCount)
+	spectrum := analyzeSpectrum(window, sampleRate, binCount, m.floorDB)
 
 	m.mu.Lock()
 	for i, v := range spectrum {
@@ -362,17 +435,20 @@ func (m *audioMeter) NamedBands() []namedBandLevel {
 	return levels
 }
 
-func analyzeSpectrum(window []float64, sampleRate float64, bandCount int) []float64 {
-	if len(window) != spectrumFFTSize || bandCount <= 0 {
+// analyzeSpectrum measures bandCount log-spaced bands of window, whose
+// length must be a power of two, as levels between floorDB and 0 dBFS.
+func analyzeSpectrum(window []float64, sampleRate float64, bandCount int, floorDB float64) []float64 {
+	fftSize := len(window)
+	if fftSize < 2 || fftSize&(fftSize-1) != 0 || bandCount <= 0 {
 		return make([]float64, max(0, bandCount))
 	}
 	if sampleRate <= 0 {
 		sampleRate = 48000
 	}
 
-	input := make([]complex128, spectrumFFTSize)
+	input := make([]complex128, fftSize)
 	for i, sample := range window {
-		windowGain := 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(spectrumFFTSize-1)))
+		windowGain := 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(fftSize-1)))
 		input[i] = complex(sample*windowGain, 0)
 	}
 
@@ -389,22 +465,22 @@ func analyzeSpectrum(window []float64, sampleRate float64, bandCount int) []floa
 		low := logarithmicFrequency(float64(i)/float64(bandCount), spectrumMinFreq, maxFreq)
 		high := logarithmicFrequency(float64(i+1)/float64(bandCount), spectrumMinFreq, maxFreq)
 
-		start := frequencyBin(low, sampleRate)
-		end := frequencyBin(high, sampleRate)
+		start := frequencyBin(low, sampleRate, fftSize)
+		end := frequencyBin(high, sampleRate, fftSize)
 		if start < 1 {
 			start = 1
 		}
 		if end <= start {
 			end = start + 1
 		}
-		if end > spectrumFFTSize/2 {
-			end = spectrumFFTSize / 2
+		if end > fftSize/2 {
+			end = fftSize / 2
 		}
 
 		var power float64
 		count := 0
 		for k := start; k < end; k++ {
-			magnitude := cmplx.Abs(input[k]) * 2 / float64(spectrumFFTSize)
+			magnitude := cmplx.Abs(input[k]) * 2 / float64(fftSize)
 			power += magnitude * magnitude
 			count++
 		}
@@ -414,7 +490,7 @@ func analyzeSpectrum(window []float64, sampleRate float64, bandCount int) []floa
 
 		rms := math.Sqrt(power / float64(count))
 		db := 20 * math.Log10(rms+1e-9)
-		normalized := (db - spectrumFloorDB) / -spectrumFloorDB
+		normalized := (db - floorDB) / -floorDB
 		normalized = max(0, min(normalized, 1))
 
 		// Slightly lift quiet details so the spectrum stays legible in a text UI.
@@ -431,13 +507,13 @@ func logarithmicFrequency(position, minFreq, maxFreq float64) float64 {
 	return minFreq * math.Pow(maxFreq/minFreq, position)
 }
 
-func frequencyBin(freq, sampleRate float64) int {
+func frequencyBin(freq, sampleRate float64, fftSize int) int {
 	if sampleRate <= 0 || freq <= 0 {
 		return 0
 	}
 
-	bin := int(math.Round(freq * float64(spectrumFFTSize) / sampleRate))
-	return max(0, min(bin, spectrumFFTSize/2))
+	bin := int(math.Round(freq * float64(fftSize) / sampleRate))
+	return max(0, min(bin, fftSize/2))
 }
 
 func fft(values []complex128) {
@@ -516,47 +592,140 @@ func (m model) volumeLabel() string {
 	return fmt.Sprintf("%d%%", m.volume)
 }
 
+// playbackStreamer wraps the mixed output of every playing track with the
+// meter, the equalizer, the volume control and a limiter that keeps boosted
+// volume, EQ and overlapping crossfades from clipping.
 func (m *model) playbackStreamer(streamer beep.Streamer) beep.Streamer {
-	streamer = m.visualizerStreamer(streamer)
-
-	scale := m.volumeScale()
-	volume := &effects.Volume{
-		Streamer: streamer,
+	m.eq = dsp.NewEqualizer(m.visualizerStreamer(streamer), m.sampleRate, m.equalizer)
+	m.output = &effects.Volume{
+		Streamer: m.eq,
 		Base:     2,
-		Silent:   m.muted || scale <= 0,
 	}
+	m.applyVolume()
+	m.limiter = dsp.NewLimiter(m.output, m.sampleRate)
+	return m.limiter
+}
+
+// limiterLabel reports recent gain reduction, or "" while the output is
+// below the ceiling.
+func (m model) limiterLabel() string {
+	if m.limiter == nil {
+		return ""
+	}
+	speaker.Lock()
+	reduction := m.limiter.Reduction()
+	speaker.Unlock()
+	if reduction <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("limit -%.1f dB", reduction)
+}
+
+func (m *model) applyVolume() {
+	if m.output == nil {
+		return
+	}
+
+	scale := m.volumeScale()
+	speaker.Lock()
+	m.output.Silent = m.muted || scale <= 0
 	if scale > 0 {
-		volume.Volume = math.Log2(scale)
+		m.output.Volume = math.Log2(scale)
 	}
-	return volume
+	speaker.Unlock()
 }
 
 func (m *model) updatePlaybackLoop() error {
-	if m.playing.Control.Ctrl == nil || m.playing.Control.Source == nil {
+	if err := m.configurePlayback(&m.playing); err != nil {
+		return err
+	}
+	if m.preloaded != nil {
+		return m.configurePlayback(&m.preloaded.track)
+	}
+	return nil
+}
+
+// configurePlayback rebuilds the loop, speed and normalization gain behind
+// t's control to match the current player settings.
+func (m *model) configurePlayback(t *track.Track) error {
+	if t.Control.Ctrl == nil || t.Control.Source == nil {
 		return nil
 	}
 
-	m.playing.Control.Loop = m.loopMode == loopCurrent
+	t.Control.Loop = m.loopMode == loopCurrent
 
-	streamer := beep.Streamer(m.playing.Control.Source)
-	if m.playing.Control.Loop {
-		looped, err := beep.Loop2(m.playing.Control.Source)
+	streamer := beep.Streamer(t.Control.Source)
+	switch {
+	case t.Repeat.Active():
+		rate := t.Format.SampleRate
+		looped, err := beep.Loop2(t.Control.Source, beep.LoopBetween(rate.N(t.Repeat.A), rate.N(t.Repeat.B)))
+		if err != nil {
+			return err
+		}
+		streamer = looped
+	case t.Control.Loop:
+		looped, err := beep.Loop2(t.Control.Source)
 		if err != nil {
 			return err
 		}
 		streamer = looped
 	}
+	if rate := m.speedRate(); rate != 1 {
+		if m.preservePitch {
+			streamer = dsp.NewTimeStretch(streamer, t.Format.SampleRate, rate)
+		} else {
+			streamer = beep.ResampleRatio(4, rate, streamer)
+		}
+	}
+	if db, ok := m.trackGainDB(*t); ok {
+		streamer = &effects.Gain{Streamer: streamer, Gain: math.Pow(10, db/20) - 1}
+	}
 
 	speaker.Lock()
-	m.playing.Control.Streamer = m.playbackStreamer(streamer)
+	t.Control.Streamer = streamer
+	t.Control.Speed = m.speedRate()
+	t.Control.PreservePitch = m.preservePitch
 	speaker.Unlock()
 
 	return nil
 }
 
 func (m *model) adjustVolume(delta int) error {
-	m.volume = max(0, min(m.volume+delta, maxVolumePercent))
-	if !m.isPlaying() {
+	m.volume = max(0, min(m.volume+delta, m.maxVolume()))
+	m.applyVolume()
+	return nil
+}
+
+func (m model) speedRate() float64 {
+	if m.speed <= 0 {
+		return 1
+	}
+	return float64(m.speed) / 100
+}
+
+func (m model) speedLabel() string {
+	label := fmt.Sprintf("speed %gx", m.speedRate())
+	if m.preservePitch {
+		label += " pitch lock"
+	}
+	return label
+}
+
+func (m *model) adjustSpeed(delta int) error {
+	previous := m.speed
+	if previous <= 0 {
+		previous = 100
+	}
+	m.speed = max(minSpeedPercent, min(previous+delta, maxSpeedPercent))
+	if m.speed == previous {
+		return nil
+	}
+	return m.updatePlaybackLoop()
+}
+
+func (m *model) togglePreservePitch() error {
+	m.preservePitch = !m.preservePitch
+	if m.speedRate() == 1 {
 		return nil
 	}
 	return m.updatePlaybackLoop()
@@ -564,39 +733,106 @@ func (m *model) adjustVolume(delta int) error {
 
 func (m *model) toggleMute() error {
 	m.muted = !m.muted
-	if !m.isPlaying() {
+	m.applyVolume()
+	return nil
+}
+
+// repeatsCurrent reports whether the playing track loops instead of ending.
+func (m model) repeatsCurrent() bool {
+	return m.loopMode == loopCurrent || m.playing.Repeat.Active()
+}
+
+// cycleRepeat sets point A, then point B, then clears the A-B repeat of the
+// playing track. Points can be set in either order.
+func (m *model) cycleRepeat() error {
+	if !m.isPlaying() || m.playing.Format == nil || m.playing.Control.Source == nil {
 		return nil
 	}
+	position := m.playing.Position()
+	repeat := m.playing.Repeat
+	switch {
+	case !repeat.HasA:
+		m.playing.Repeat = track.Repeat{A: position, HasA: true}
+		return nil
+	case !repeat.HasB:
+		a, b := min(repeat.A, position), max(repeat.A, position)
+		rate := m.playing.Format.SampleRate
+		if rate.N(b) <= rate.N(a) {
+			return nil
+		}
+		m.playing.Repeat = track.Repeat{A: a, B: b, HasA: true, HasB: true}
+	default:
+		m.playing.Repeat = track.Repeat{}
+	}
+	// The next track cannot start while a segment repeats.
+	m.discardPreloaded()
 	return m.updatePlaybackLoop()
 }
 
+func (m model) repeatLabel() string {
+	switch repeat := m.playing.Repeat; {
+	case repeat.Active():
+		return "a-b repeat"
+	case repeat.HasA:
+		return "a-b: set B"
+	default:
+		return ""
+	}
+}
+
 func (m *model) finishCurrentTrack() (tea.Cmd, error) {
 	if m.transitioning {
 		return nil, nil
 	}
 	previous := m.playing.Control.Source
 	if m.loopMode == loopQueue {
-		m.enqueueTrack(m.playingPath, m.playing.Title)
+		m.requeue(m.playingPath, m.playing.Title, m.playing.Tags)
 	}
 	if next, ok := m.dequeueNext(); ok {
 		m.transitioning = true
 		return m.playSongCmdWithPrevious(next.path, previous), nil
 	}
+	m.pushHistory()
 	return nil, m.stopPlayback()
 }
 
+func (m model) canSeek() bool {
+	return m.isPlaying() && m.playing.Format != nil && m.playing.Control.Source != nil
+}
+
 func (m *model) seekBy(delta time.Duration) (tea.Cmd, error) {
-	if !m.isPlaying() || m.playing.Format == nil || m.playing.Control.Source == nil {
+	if !m.canSeek() {
 		return nil, nil
 	}
-
-	sampleDelta := m.playing.Format.SampleRate.N(delta)
 	current := m.playing.Control.Source.Position()
-	target := current + sampleDelta
+	return m.seekToSample(current + m.playing.Format.SampleRate.N(delta))
+}
+
+// seekTo moves to an absolute position in track time.
+func (m *model) seekTo(position time.Duration) (tea.Cmd, error) {
+	if !m.canSeek() {
+		return nil, nil
+	}
+	return m.seekToSample(m.playing.Format.SampleRate.N(position))
+}
+
+// seekPercent moves to a fraction of the track's length.
+func (m *model) seekPercent(percent float64) (tea.Cmd, error) {
+	if !m.canSeek() {
+		return nil, nil
+	}
+	return m.seekToSample(int(percent * float64(m.playing.Control.Source.Len())))
+}
+
+func (m *model) seekToSample(target int) (tea.Cmd, error) {
+	rate := m.playing.Format.SampleRate
 	if target < 0 {
 		target = 0
 	}
-	if length := m.playing.Control.Source.Len(); target >= length {
+	if repeat := m.playing.Repeat; repeat.Active() {
+		// Seeks stay inside the repeated segment instead of leaving it.
+		target = max(rate.N(repeat.A), min(target, rate.N(repeat.B)-1))
+	} else if length := m.playing.Control.Source.Len(); target >= length {
 		return m.finishCurrentTrack()
 	}
 
@@ -616,36 +852,34 @@ func (m *model) toggleLoopMode() error {
 	return nil
 }
 
+// loadTrack decodes the file at path and gathers its tags and cover art.
+func loadTrack(path string) (loadedTrackMsg, error) {
+	streamer, format, err := decode.File(path)
+	if err != nil {
+		return loadedTrackMsg{}, fmt.Errorf("decode %s: %w", filepath.Base(path), err)
+	}
+	// Missing or malformed tags only cost the display title.
+	tags, _ := metadata.Read(path)
+	length := format.SampleRate.D(streamer.Len())
+	loaded := track.New(streamer, &format, tags.DisplayTitle(filepath.Base(path)), length)
+	loaded.Tags = tags
+	// Most tracks have no cover; the player shows a placeholder instead.
+	art, _ := artwork.Load(path)
+	return loadedTrackMsg{
+		track:   loaded,
+		path:    path,
+		artwork: art,
+	}, nil
+}
+
 func (m *model) playSongCmdWithPrevious(path string, previous beep.StreamSeekCloser) tea.Cmd {
 	return func() tea.Msg {
-		f, err := os.Open(path)
+		msg, err := loadTrack(path)
 		if err != nil {
-			return errorMsg(fmt.Errorf("open %s: %w", filepath.Base(path), err))
-		}
-
-		var (
-			streamer beep.StreamSeekCloser
-			format   beep.Format
-		)
-		switch strings.ToLower(filepath.Ext(path)) {
-		case ".mp3":
-			streamer, format, err = mp3.Decode(f)
-		case ".wav":
-			streamer, format, err = wav.Decode(f)
-		default:
-			err = fmt.Errorf("unsupported audio format: %s", filepath.Ext(path))
-		}
-		if err != nil {
-			_ = f.Close()
-			return errorMsg(fmt.Errorf("decode %s: %w", filepath.Base(path), err))
-		}
-		title := filepath.Base(path)
-		length := format.SampleRate.D(streamer.Len())
-		return loadedTrackMsg{
-			track:    track.New(streamer, &format, title, length),
-			path:     path,
-			previous: previous,
+			return errorMsg(err)
 		}
+		msg.previous = previous
+		return msg
 	}
 }
 
@@ -671,9 +905,13 @@ func (m *model) stopPlayback() error {
 	}
 
 	speaker.Clear()
+	m.releasePreloaded()
+	m.sequence = nil
+	m.finishFadeOut()
 	err := closeStream(m.playing.Control.Source)
 	m.playing = track.Track{}
 	m.playingPath = ""
+	m.artwork = nil
 	m.transitioning = false
 	if m.meter != nil {
 		m.meter.Reset()
@@ -681,7 +919,27 @@ func (m *model) stopPlayback() error {
 	return err
 }
 
-func (m *model) enqueueTrack(path, title string) bool {
+func (m *model) enqueueTrack(path, title string, tags metadata.Tags) bool {
+	if m.shuffle {
+		return m.insertCurrentPass(m.shuffleIndex(false), path, title, tags)
+	}
+	return m.insertQueued(len(m.queue), path, title, tags)
+}
+
+// enqueueNext puts a track at the head of the queue, right after the one
+// playing.
+func (m *model) enqueueNext(path, title string, tags metadata.Tags) bool {
+	if !m.insertCurrentPass(0, path, title, tags) {
+		return false
+	}
+	// Keep the cursor on the entry it was on.
+	if len(m.queue) > 1 {
+		m.queueCursor++
+	}
+	return true
+}
+
+func (m *model) insertQueued(index int, path, title string, tags metadata.Tags) bool {
 	if path == "" {
 		return false
 	}
@@ -689,20 +947,32 @@ func (m *model) enqueueTrack(path, title string) bool {
 		title = filepath.Base(path)
 	}
 
-	m.queue = append(m.queue, queuedTrack{
+	m.queue = slices.Insert(m.queue, index, queuedTrack{
 		path:  path,
 		title: title,
+		tags:  tags,
 	})
 	return true
 }
 
 func (m *model) enqueueSelected() bool {
 	path, ok := m.tracks.selectedFilePath()
-	if !ok {
+	if !ok || !isSupportedAudioFile(path) {
 		return false
 	}
 
-	return m.enqueueTrack(path, filepath.Base(path))
+	tags, _ := metadata.Read(path)
+	return m.enqueueTrack(path, tags.DisplayTitle(filepath.Base(path)), tags)
+}
+
+func (m *model) enqueueSelectedNext() bool {
+	path, ok := m.tracks.selectedFilePath()
+	if !ok || !isSupportedAudioFile(path) {
+		return false
+	}
+
+	tags, _ := metadata.Read(path)
+	return m.enqueueNext(path, tags.DisplayTitle(filepath.Base(path)), tags)
 }
 
 func (m *model) clampQueueCursor() {
@@ -724,8 +994,14 @@ func (m *model) moveQueueCursor(delta int) {
 	m.clampQueueCursor()
 }
 
+// queuePaneEmpty reports whether the queue pane has nothing to focus: no
+// queued tracks and no history to browse.
+func (m model) queuePaneEmpty() bool {
+	return len(m.queue) == 0 && len(m.history) == 0
+}
+
 func (m *model) ensureFocusablePane() {
-	if m.focus == focusQueue && len(m.queue) == 0 {
+	if m.focus == focusQueue && m.queuePaneEmpty() {
 		m.focus = focusTracks
 	}
 }
@@ -736,7 +1012,7 @@ func (m *model) focusNextPane() {
 		return
 	}
 
-	if len(m.queue) == 0 {
+	if m.queuePaneEmpty() {
 		m.focus = focusTracks
 		return
 	}
@@ -753,18 +1029,50 @@ func (m *model) dequeueSelected() (queuedTrack, bool) {
 	m.clampQueueCursor()
 	selected := m.queue[m.queueCursor]
 	m.queue = append(m.queue[:m.queueCursor], m.queue[m.queueCursor+1:]...)
+	m.removedFromQueue(m.queueCursor)
 	m.clampQueueCursor()
 	m.ensureFocusablePane()
 	return selected, true
 }
 
+// moveSelected shifts the selected queue entry by delta places and keeps it
+// selected.
+func (m *model) moveSelected(delta int) bool {
+	if len(m.queue) == 0 {
+		return false
+	}
+	m.clampQueueCursor()
+	target := max(0, min(m.queueCursor+delta, len(m.queue)-1))
+	if target == m.queueCursor {
+		return false
+	}
+	selected := m.queue[m.queueCursor]
+	m.queue = slices.Insert(slices.Delete(m.queue, m.queueCursor, m.queueCursor+1), target, selected)
+	m.movedInQueue(m.queueCursor, target)
+	m.queueCursor = target
+	return true
+}
+
+func (m *model) moveSelectedToTop() bool {
+	return m.moveSelected(-len(m.queue))
+}
+
+func (m model) peekNext() (queuedTrack, bool) {
+	if len(m.queue) == 0 {
+		return queuedTrack{}, false
+	}
+	return m.queue[0], true
+}
+
 func (m *model) dequeueNext() (queuedTrack, bool) {
 	if len(m.queue) == 0 {
 		return queuedTrack{}, false
 	}
 
+	m.startPass()
 	next := m.queue[0]
 	m.queue = m.queue[1:]
+	m.removedFromQueue(0)
 	if m.queueCursor > 0 {
 		m.queueCursor--
 	}
@@ -792,28 +1100,25 @@ func boundedWidth(width int) int {
 	return width
 }
 
-func trackPanelStyle(focused bool) lipgloss.Style {
-	borderColor := lipgloss.Color("#89dceb")
+func panelBorderColor(focused bool) color.Color {
 	if focused {
-		borderColor = lipgloss.Color("#f5c2e7")
+		return lipgloss.Color(theme.Active().Focus)
 	}
+	return lipgloss.Color(theme.Active().Border)
+}
 
+func trackPanelStyle(focused bool) lipgloss.Style {
 	return lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(borderColor).
+		BorderForeground(panelBorderColor(focused)).
 		Padding(0, 1)
 }
 
 func queuePanelStyle(focused bool, width int) lipgloss.Style {
-	borderColor := lipgloss.Color("#89dceb")
-	if focused {
-		borderColor = lipgloss.Color("#f5c2e7")
-	}
-
 	return lipgloss.NewStyle().
 		Width(boundedWidth(width)).
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(borderColor).
+		BorderForeground(panelBorderColor(focused)).
 		Padding(0, 1)
 }
 
@@ -821,17 +1126,21 @@ func spectrogramPanelStyle(width int) lipgloss.Style {
 	return lipgloss.NewStyle().
 		Width(boundedWidth(width)).
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#f9e2af")).
+		BorderForeground(lipgloss.Color(theme.Active().Accent)).
 		Padding(0, 1)
 }
 
 func playerHelpPanelStyle() lipgloss.Style {
 	return lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#94e2d5")).
+		BorderForeground(lipgloss.Color(theme.Active().Info)).
 		Padding(0, 1)
 }
 
+func artworkPlaceholderStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Active().Muted))
+}
+
 func (m *model) queueView() string {
 	return m.queueViewWithWidth(queuePanelContentWidth)
 }
@@ -874,6 +1183,16 @@ func queueViewport(lines []string, contentHeight, selectedStart, selectedEnd int
 	if contentHeight < 0 || len(lines) <= contentHeight {
 		return lines
 	}
+	start := queueViewportStart(len(lines), contentHeight, selectedStart, selectedEnd)
+	return lines[start:min(start+contentHeight, len(lines))]
+}
+
+// queueViewportStart is the first line shown when the queue is scrolled to
+// keep the selected item visible.
+func queueViewportStart(lineCount, contentHeight, selectedStart, selectedEnd int) int {
+	if contentHeight < 0 || lineCount <= contentHeight {
+		return 0
+	}
 
 	if selectedStart < 0 {
 		selectedStart = 0
@@ -891,32 +1210,25 @@ func queueViewport(lines []string, contentHeight, selectedStart, selectedEnd int
 		start = selectedStart
 	}
 
-	maxStart := len(lines) - contentHeight
+	maxStart := lineCount - contentHeight
 	if start > maxStart {
 		start = maxStart
 	}
 	if start < 0 {
 		start = 0
 	}
-
-	end := start + contentHeight
-	if end > len(lines) {
-		end = len(lines)
-	}
-
-	return lines[start:end]
+	return start
 }
 
 func (m *model) queueViewWithWidth(width int) string {
 	return m.queueViewWithSize(width, -1)
 }
 
-func (m *model) queueViewWithSize(width, contentHeight int) string {
-	queueStyle := queuePanelStyle(m.focus == focusQueue, width)
-	contentWidth := boundedWidth(width - queueStyle.GetHorizontalFrameSize())
-
-	lines := []string{fmt.Sprintf("Queue (%d)", len(m.queue))}
-	selectedStart, selectedEnd := -1, -1
+// queueLines lays out the queue content. owners maps each line to the queue
+// index it belongs to, or -1.
+func (m model) queueLines(contentWidth int) (lines []string, owners []int, selectedStart, selectedEnd int) {
+	lines = []string{fmt.Sprintf("Queue (%d)", len(m.queue))}
+	selectedStart, selectedEnd = -1, -1
 	if m.playing.Title != "" {
 		lines = append(lines, "", "Playing")
 		appendQueueBlock(&lines, wrapQueueItem("  ", m.playing.Title, contentWidth))
@@ -932,12 +1244,51 @@ func (m *model) queueViewWithSize(width, contentHeight int) string {
 				prefix = "› "
 			}
 			start, end := appendQueueBlock(&lines, wrapQueueItem(fmt.Sprintf("%s%d. ", prefix, i+1), item.title, contentWidth))
+			for len(owners) < start {
+				owners = append(owners, -1)
+			}
+			for len(owners) < end {
+				owners = append(owners, i)
+			}
 			if m.focus == focusQueue && i == m.queueCursor {
 				selectedStart, selectedEnd = start, end
 			}
 		}
 	}
+	for len(owners) < len(lines) {
+		owners = append(owners, -1)
+	}
+	return lines, owners, selectedStart, selectedEnd
+}
 
+// queueItemAt returns the queue index drawn on a content row of the queue
+// panel, or -1.
+func (m model) queueItemAt(row, width, contentHeight int) int {
+	if m.showHistory {
+		return -1
+	}
+	contentWidth := boundedWidth(width - queuePanelStyle(m.focus == focusQueue, width).GetHorizontalFrameSize())
+	lines, owners, selectedStart, selectedEnd := m.queueLines(contentWidth)
+	row += queueViewportStart(len(lines), contentHeight, selectedStart, selectedEnd)
+	if row < 0 || row >= len(owners) {
+		return -1
+	}
+	return owners[row]
+}
+
+func (m *model) queueViewWithSize(width, contentHeight int) string {
+	queueStyle := queuePanelStyle(m.focus == focusQueue, width)
+	contentWidth := boundedWidth(width - queueStyle.GetHorizontalFrameSize())
+
+	var (
+		lines                      []string
+		selectedStart, selectedEnd int
+	)
+	if m.showHistory {
+		lines, selectedStart, selectedEnd = m.historyLines(contentWidth)
+	} else {
+		lines, _, selectedStart, selectedEnd = m.queueLines(contentWidth)
+	}
 	if contentHeight >= 0 {
 		lines = queueViewport(lines, contentHeight, selectedStart, selectedEnd)
 	}
@@ -1062,23 +1413,22 @@ func spectrogramCell(normalized float64) string {
 	return lipgloss.NewStyle().Foreground(lipgloss.Color(spectrogramColor(normalized))).Render(string(levels[level]))
 }
 
+// spectrogramColor picks the active theme's color for a level in [0, 1];
+// quiet levels are drawn muted.
 func spectrogramColor(normalized float64) string {
-	color := "#6c7086"
-	switch {
-	case normalized > 0.82:
-		color = "#f38ba8"
-	case normalized > 0.68:
-		color = "#fab387"
-	case normalized > 0.52:
-		color = "#f9e2af"
-	case normalized > 0.36:
-		color = "#a6e3a1"
-	case normalized > 0.20:
-		color = "#74c7ec"
+	palette := theme.Active()
+	for i, threshold := range spectrumThresholds {
+		if normalized > threshold {
+			return palette.Spectrum[len(spectrumThresholds)-1-i]
+		}
 	}
-	return color
+	return palette.Muted
 }
 
+// spectrumThresholds are the lower bounds of the theme's spectrum colors,
+// loudest first.
+var spectrumThresholds = [theme.SpectrumLevels]float64{0.82, 0.68, 0.52, 0.36, 0.20}
+
 func bandMeter(value float64, width int) string {
 	if width <= 0 {
 		return ""
@@ -1091,7 +1441,7 @@ func bandMeter(value float64, width int) string {
 	b.Grow(width)
 	for i := 0; i < width; i++ {
 		ch := "·"
-		color := "#585b70"
+		color := theme.Active().Grid
 		if i < filled {
 			ch = "█"
 			color = spectrogramColor(value)
@@ -1121,10 +1471,16 @@ func tickCmd() tea.Cmd {
 }
 
 func (m model) Init() tea.Cmd {
-	return m.tracks.Init()
+	if m.startPath != "" {
+		return tea.Batch(m.tracks.Init(), m.playSongCmd(m.startPath))
+	}
+	return tea.Batch(m.tracks.Init(), m.resumeCmd())
 }
 
 func (m model) helpFocus() help.FocusArea {
+	if m.eqEditor {
+		return help.FocusEqualizer
+	}
 	if m.focus == focusQueue {
 		return help.FocusQueue
 	}
@@ -1146,6 +1502,33 @@ func (m model) playbackMeta() string {
 	if m.loopMode != loopOff {
 		parts = append(parts, fmt.Sprintf("loop %s", m.loopMode))
 	}
+	if m.shuffle {
+		parts = append(parts, "shuffle")
+	}
+	if repeat := m.repeatLabel(); repeat != "" {
+		parts = append(parts, repeat)
+	}
+	if m.speedRate() != 1 {
+		parts = append(parts, m.speedLabel())
+	}
+	if m.gapless {
+		parts = append(parts, "gapless")
+	}
+	if m.crossfade > 0 {
+		parts = append(parts, fmt.Sprintf("xfade %s", m.crossfade))
+	}
+	if m.gainMode != gainOff {
+		parts = append(parts, m.gainLabel())
+	}
+	if eq := m.equalizerLabel(); eq != "" {
+		parts = append(parts, eq)
+	}
+	if limiting := m.limiterLabel(); limiting != "" {
+		parts = append(parts, limiting)
+	}
+	if m.notice != "" {
+		parts = append(parts, m.notice)
+	}
 	return strings.Join(parts, " • ")
 }
 
@@ -1153,7 +1536,7 @@ func (m model) playerHelpView() string {
 	contentWidth := m.playerHelpContentWidth()
 	statusStyle := lipgloss.NewStyle().Padding(0, 1).MaxWidth(contentWidth)
 
-	lines := make([]string, 0, 4)
+	lines := make([]string, 0, 3)
 	if m.err != nil {
 		lines = append(lines, statusStyle.Render(fmt.Sprintf("❌ Error: %v", m.err)))
 	} else if m.playing.Title != "" {
@@ -1161,15 +1544,28 @@ func (m model) playerHelpView() string {
 		if m.playing.Control.Paused {
 			statusText = fmt.Sprintf("⏸ Paused: %s", m.playing.Title)
 		}
-		lines = append(lines, statusStyle.Render(statusText))
-		lines = append(lines, statusStyle.Render(m.playing.String()))
-		lines = append(lines, statusStyle.Render(m.playbackMeta()))
+		status := []string{statusText}
+		if details := m.playing.Tags.Details(); details != "" {
+			status = append(status, details)
+		}
+		status = append(status, m.playing.String(), m.playbackMeta())
+
+		art, artWidth := m.artworkView(len(status), contentWidth)
+		statusStyle = statusStyle.MaxWidth(contentWidth - artWidth)
+		for i, line := range status {
+			status[i] = statusStyle.Render(line)
+		}
+		lines = append(lines, lipgloss.JoinHorizontal(lipgloss.Top, art, strings.Join(status, "\n")))
 	} else {
 		statusText := "Select an audio file to play."
 		lines = append(lines, statusStyle.Render(statusText))
 		lines = append(lines, statusStyle.Render(m.playbackMeta()))
 	}
 
+	if m.prompting {
+		lines = append(lines, statusStyle.Render(m.prompt.View()))
+	}
+
 	if helpView := m.help.ViewWithWidth(m.helpFocus(), contentWidth); helpView != "" {
 		lines = append(lines, helpView)
 	}
@@ -1179,6 +1575,25 @@ func (m model) playerHelpView() string {
 		Render(truncateBlock(strings.Join(lines, "\n"), contentWidth))
 }
 
+// artworkView renders the cover art beside the now playing status, height
+// rows tall and twice as many columns wide so half-block pixels stay square.
+// Without art, without 24-bit color or when the panel is too narrow for a
+// full-size cover, it falls back to a placeholder.
+func (m model) artworkView(height, contentWidth int) (string, int) {
+	width := height * 2
+	if contentWidth-width < minArtworkStatusWidth {
+		width = compactArtworkWidth
+		if contentWidth-width < minArtworkStatusWidth {
+			return "", 0
+		}
+		return artworkPlaceholderStyle().Render(artwork.Placeholder(width, height)), width
+	}
+	if m.artwork == nil || m.colorProfile < colorprofile.TrueColor {
+		return artworkPlaceholderStyle().Render(artwork.Placeholder(width, height)), width
+	}
+	return artwork.Render(m.artwork, width, height), width
+}
+
 func (m model) visualizerView(width, plotHeight int) string {
 	if width <= 0 || plotHeight <= 0 {
 		return ""
@@ -1231,6 +1646,9 @@ func (m model) visualizerView(width, plotHeight int) string {
 func (m model) spectrogramPanelView(width, plotHeight int) string {
 	style := spectrogramPanelStyle(width)
 	contentWidth := boundedWidth(width - style.GetHorizontalFrameSize())
+	if m.eqEditor {
+		return style.Render(truncateBlock(m.equalizerView(contentWidth, plotHeight), contentWidth))
+	}
 	return style.Render(truncateBlock(m.visualizerView(contentWidth, plotHeight), contentWidth))
 }
 
@@ -1256,6 +1674,17 @@ func (m *model) syncTracksViewportHeight() {
 	m.tracks.setHeight(m.tracksViewHeight(topHeight))
 }
 
+// screenLayout holds the rendered panes and the geometry mouse events are
+// mapped against.
+type screenLayout struct {
+	top, bottom        string
+	leftWidth          int
+	rightX             int
+	queueWidth         int
+	queueHeight        int
+	queueContentHeight int
+}
+
 func (m model) render() string {
 	if m.help.GetshowHelp() {
 		var b strings.Builder
@@ -1263,6 +1692,11 @@ func (m model) render() string {
 		b.WriteString(m.help.ListView(m.helpFocus()))
 		return b.String()
 	}
+	layout := m.layout()
+	return lipgloss.JoinVertical(lipgloss.Left, layout.top, layout.bottom)
+}
+
+func (m model) layout() screenLayout {
 	bottom := m.playerHelpView()
 	topHeight := m.topPaneHeight(bottom)
 
@@ -1292,20 +1726,36 @@ func (m model) render() string {
 	top := lipgloss.JoinHorizontal(lipgloss.Top, left, strings.Repeat(" ", sizing.gap), right)
 	top = truncateBlock(top, m.windowWidth())
 	top = truncateBlockHeight(top, topHeight)
-	return lipgloss.JoinVertical(lipgloss.Left, top, bottom)
+	return screenLayout{
+		top:                top,
+		bottom:             bottom,
+		leftWidth:          lipgloss.Width(left),
+		rightX:             lipgloss.Width(left) + sizing.gap,
+		queueWidth:         sizing.queueWidth,
+		queueHeight:        lipgloss.Height(queue),
+		queueContentHeight: queueContentHeight,
+	}
 }
 
 func (m model) View() tea.View {
 	v := tea.NewView(m.render())
 	v.AltScreen = true
+	v.MouseMode = tea.MouseModeCellMotion
 	return v
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyPressMsg:
+		if m.prompting {
+			return m.updatePrompt(msg)
+		}
+		m.notice = ""
 		switch {
 		case key.Matches(msg, m.help.Keys().Global.Quit):
+			if err := m.saveSession(); err != nil {
+				log.Printf("error saving session: %v", err)
+			}
 			if err := m.stopPlayback(); err != nil {
 				log.Printf("error closing active track: %v", err)
 			}
@@ -1322,11 +1772,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			speaker.Lock()
 			m.playing.Control.Paused = !m.playing.Control.Paused
+			if m.fadingOut.Control.Ctrl != nil {
+				m.fadingOut.Control.Paused = m.playing.Control.Paused
+			}
 			speaker.Unlock()
 			return m, nil
 
 		case key.Matches(msg, m.help.Keys().Global.SeekBack):
-			cmd, err := m.seekBy(-seekStep)
+			cmd, err := m.seekBy(-m.smallSeekStep())
 			if err != nil {
 				m.err = err
 			} else {
@@ -1335,7 +1788,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 
 		case key.Matches(msg, m.help.Keys().Global.SeekAhead):
-			cmd, err := m.seekBy(seekStep)
+			cmd, err := m.seekBy(m.smallSeekStep())
 			if err != nil {
 				m.err = err
 			} else {
@@ -1343,8 +1796,41 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, cmd
 
+		case key.Matches(msg, m.help.Keys().Global.SeekBackLarge):
+			cmd, err := m.seekBy(-m.largeSeekStep())
+			if err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, cmd
+
+		case key.Matches(msg, m.help.Keys().Global.SeekAheadLarge):
+			cmd, err := m.seekBy(m.largeSeekStep())
+			if err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, cmd
+
+		case key.Matches(msg, m.help.Keys().Global.JumpPercent):
+			cmd, err := m.seekPercent(jumpPercent(m.help.Keys().Global.JumpPercent, msg.String()))
+			if err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, cmd
+
+		case key.Matches(msg, m.help.Keys().Global.SeekTo):
+			return m, m.openSeekPrompt()
+
+		case key.Matches(msg, m.help.Keys().Global.SavePlaylist):
+			return m, m.openSavePlaylistPrompt()
+
 		case key.Matches(msg, m.help.Keys().Global.VolumeDown):
-			if err := m.adjustVolume(-volumeStep); err != nil {
+			if err := m.adjustVolume(-m.volumeStepPercent()); err != nil {
 				m.err = err
 			} else {
 				m.err = nil
@@ -1352,7 +1838,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 
 		case key.Matches(msg, m.help.Keys().Global.VolumeUp):
-			if err := m.adjustVolume(volumeStep); err != nil {
+			if err := m.adjustVolume(m.volumeStepPercent()); err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, nil
+
+		case key.Matches(msg, m.help.Keys().Global.SpeedDown):
+			if err := m.adjustSpeed(-speedStep); err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, nil
+
+		case key.Matches(msg, m.help.Keys().Global.SpeedUp):
+			if err := m.adjustSpeed(speedStep); err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, nil
+
+		case key.Matches(msg, m.help.Keys().Global.KeepPitch):
+			if err := m.togglePreservePitch(); err != nil {
 				m.err = err
 			} else {
 				m.err = nil
@@ -1380,18 +1890,121 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.err = nil
 			return m, nil
 
+		case key.Matches(msg, m.help.Keys().Global.Previous):
+			cmd, err := m.playPrevious()
+			if err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, cmd
+
+		case key.Matches(msg, m.help.Keys().Global.Next):
+			cmd, err := m.playNext()
+			if err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, cmd
+
+		case key.Matches(msg, m.help.Keys().Global.Shuffle):
+			m.toggleShuffle()
+			return m, nil
+
+		case key.Matches(msg, m.help.Keys().Global.ABRepeat):
+			if err := m.cycleRepeat(); err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, nil
+
+		case key.Matches(msg, m.help.Keys().Global.Gapless):
+			m.gapless = !m.gapless
+			if !m.gapless {
+				m.discardPreloaded()
+				return m, nil
+			}
+			return m, m.preloadNextCmd()
+
+		case key.Matches(msg, m.help.Keys().Global.Crossfade):
+			m.crossfade = nextCrossfade(m.crossfade)
+			if m.crossfade > 0 {
+				m.discardPreloaded()
+			}
+			return m, nil
+
+		case key.Matches(msg, m.help.Keys().Global.ReplayGain):
+			cmd, err := m.toggleGainMode()
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			return m, cmd
+
+		case key.Matches(msg, m.help.Keys().Global.Equalizer):
+			m.cycleEqualizer()
+			return m, nil
+
+		case key.Matches(msg, m.help.Keys().Global.EqEditor):
+			m.eqEditor = !m.eqEditor
+			return m, nil
+
 		case key.Matches(msg, m.help.Keys().Global.KeyHelp):
 			m.help.ToggleShowHelp()
 			return m, nil
 		}
 
+		if m.eqEditor {
+			switch {
+			case key.Matches(msg, m.help.Keys().Equalizer.PrevBand):
+				m.selectEqualizerBand(-1)
+				return m, nil
+			case key.Matches(msg, m.help.Keys().Equalizer.NextBand):
+				m.selectEqualizerBand(1)
+				return m, nil
+			case key.Matches(msg, m.help.Keys().Equalizer.GainDown):
+				m.adjustEqualizerBand(-eqGainStep)
+				return m, nil
+			case key.Matches(msg, m.help.Keys().Equalizer.GainUp):
+				m.adjustEqualizerBand(eqGainStep)
+				return m, nil
+			}
+		}
+
 		// Focused component hotkeys are handled after globals.
 		switch m.focus {
 		case focusQueue:
+			if key.Matches(msg, m.help.Keys().Queue.History) {
+				m.showHistory = !m.showHistory
+				m.clampHistoryCursor()
+				return m, nil
+			}
+			if m.showHistory {
+				switch {
+				case key.Matches(msg, m.help.Keys().Queue.Down):
+					m.moveHistoryCursor(-1)
+				case key.Matches(msg, m.help.Keys().Queue.Up):
+					m.moveHistoryCursor(1)
+				}
+				// The history is listed newest first, so moving down the
+				// list steps back in time.
+				return m, nil
+			}
 			switch {
 			case key.Matches(msg, m.help.Keys().Queue.DequeueSelected):
 				m.dequeueSelected()
 				return m, nil
+			case key.Matches(msg, m.help.Keys().Queue.MoveUp):
+				m.moveSelected(-1)
+				return m, nil
+			case key.Matches(msg, m.help.Keys().Queue.MoveDown):
+				m.moveSelected(1)
+				return m, nil
+			case key.Matches(msg, m.help.Keys().Queue.MoveToTop):
+				m.moveSelectedToTop()
+				return m, nil
 			case key.Matches(msg, m.help.Keys().Queue.Down):
 				m.moveQueueCursor(1)
 				return m, nil
@@ -1403,15 +2016,33 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 		case focusTracks:
-			if key.Matches(msg, m.help.Keys().Tracks.QueueSelected) {
+			switch {
+			case key.Matches(msg, m.help.Keys().Tracks.QueueSelected):
+				if cmd := m.openSelectedPlaylistCmd(false); cmd != nil {
+					return m, cmd
+				}
 				m.enqueueSelected()
 				return m, nil
+			case key.Matches(msg, m.help.Keys().Tracks.QueueNext):
+				if cmd := m.openSelectedPlaylistCmd(true); cmd != nil {
+					return m, cmd
+				}
+				m.enqueueSelectedNext()
+				return m, nil
+			case key.Matches(msg, m.help.Keys().Tracks.QueueDirectory):
+				return m, m.enqueueSelectedDirectoryCmd()
 			}
 		}
 
 		if m.focus == focusQueue {
 			return m, nil
 		}
+	case tea.MouseClickMsg:
+		return m.updateMouseClick(msg.Mouse())
+
+	case tea.MouseWheelMsg:
+		return m.updateMouseWheel(msg.Mouse())
+
 	case errorMsg:
 		m.transitioning = false
 		m.err = msg
@@ -1425,22 +2056,63 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 
+	case tea.ColorProfileMsg:
+		m.colorProfile = msg.Profile
+
 	case loadedTrackMsg:
 		m.transitioning = false
-		speaker.Clear()
-		if msg.previous != nil {
-			if err := closeStream(msg.previous); err != nil {
-				log.Printf("error closing previous track: %v", err)
+		m.applyCachedLoudness(&msg.track, msg.path)
+		m.releasePreloaded()
+		// A fade still in progress is cut by the new transition.
+		staleFade := m.fadingOut.Control.Source
+		m.fadingOut = track.Track{}
+		outgoing := m.playing
+		fading := m.crossfade > 0 && msg.previous != nil &&
+			msg.previous == outgoing.Control.Source && !outgoing.Control.Paused
+		if fading {
+			// Only the track playing when the load started fades out; a
+			// paused or finished one is cut as before.
+			if err := m.configurePlayback(&msg.track); err != nil {
+				m.err = err
+				return m, nil
 			}
+			fading = m.startCrossfade(msg.track)
+		}
+		if fading {
+			m.fadingOut = outgoing
+		} else {
+			speaker.Clear()
+			if msg.previous != nil {
+				if err := closeStream(msg.previous); err != nil {
+					log.Printf("error closing previous track: %v", err)
+				}
+			}
+		}
+		if err := closeStream(staleFade); err != nil {
+			log.Printf("error closing faded track: %v", err)
 		}
 
+		if !msg.back {
+			m.pushHistory()
+		}
 		m.playing = msg.track
 		m.playingPath = msg.path
-		m.playing.Control.Paused = false
+		m.artwork = msg.artwork
+		m.playing.Control.Paused = msg.resume
+		m.resumePath = ""
 		m.err = nil
+		// A track that was not measured ahead plays at its own level this
+		// time; the scan is cached for its next play.
+		var scan tea.Cmd
+		if m.prescanned != m.playingPath {
+			scan = m.scanLoudnessCmd(m.playing, m.playingPath, false)
+		}
+		if fading {
+			return m, tea.Batch(tickCmd(), scan)
+		}
 		if m.meter != nil {
 			m.meter.Reset()
-			m.meter.SetSampleRate(m.playing.Format.SampleRate)
+			m.meter.SetSampleRate(m.sampleRate)
 		}
 
 		if err := m.updatePlaybackLoop(); err != nil {
@@ -1448,16 +2120,58 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
-		resample := beep.Resample(4, m.playing.Format.SampleRate, m.sampleRate, m.playing.Control.Ctrl)
-		speaker.Play(resample)
+		m.sequence = newTrackSequence(m.resampled(m.playing))
+		speaker.Play(m.playbackStreamer(m.sequence))
+
+		return m, tea.Batch(tickCmd(), scan)
+
+	case directoryScannedMsg:
+		if err := m.acceptDirectory(msg); err != nil {
+			m.err = err
+		}
+		return m, nil
+
+	case playlistLoadedMsg:
+		if err := m.acceptPlaylist(msg); err != nil {
+			m.err = err
+		}
+		return m, nil
+
+	case playlistSavedMsg:
+		if err := m.acceptSavedPlaylist(msg); err != nil {
+			m.err = err
+		}
+		return m, nil
 
-		return m, tickCmd()
+	case loudnessScannedMsg:
+		if err := m.acceptLoudness(msg); err != nil {
+			m.err = err
+		}
+		return m, nil
+
+	case preloadedTrackMsg:
+		m.applyCachedLoudness(&msg.track, msg.path)
+		if err := m.acceptPreloaded(loadedTrackMsg(msg)); err != nil {
+			m.err = err
+			return m, nil
+		}
+		if m.preloaded == nil || m.prescanned == m.preloaded.path {
+			return m, nil
+		}
+		return m, m.scanLoudnessCmd(m.preloaded.track, m.preloaded.path, false)
 
 	case tickMsg:
 		if !m.isPlaying() {
 			return m, nil
 		}
-		if m.loopMode != loopCurrent && m.playing.Percent() >= 1.0 {
+		if err := m.promotePreloaded(); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.finishFadeOut()
+		// A queued preload takes over on the audio thread; wait for it
+		// instead of starting the next track a second time.
+		if m.preloaded == nil && !m.repeatsCurrent() && (m.playing.Percent() >= 1.0 || m.crossfadeDue()) {
 			cmd, err := m.finishCurrentTrack()
 			if err != nil {
 				m.err = err
@@ -1466,13 +2180,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.err = nil
 			return m, cmd
 		}
-		return m, tickCmd()
+		return m, tea.Batch(tickCmd(), m.preloadNextCmd(), m.prescanNextCmd(), m.saveSessionDueCmd())
 
+	case sessionSavedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		return m, nil
 	}
 
 	m.syncTracksViewportHeight()
 	cmd, path, didSelect := m.tracks.Update(msg)
 	if didSelect {
+		if playlist.Supported(path) {
+			return m, loadPlaylistCmd(path)
+		}
 		if isSupportedAudioFile(path) {
 			return m, m.playSongCmd(path)
 		}
@@ -1481,28 +2203,85 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func main() {
-	initPath, err := filepath.Abs("./sounds")
+	opts, err := parseArgs(os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintf(os.Stderr, "tmp: %v\nRun 'tmp --help' for usage.\n", err)
+		os.Exit(2)
 	}
-	if _, err := os.Stat(initPath); os.IsNotExist(err) {
-		log.Fatalf("Directory does not exist: %s", initPath)
+	if opts.version {
+		fmt.Println("tmp", versionString())
+		return
+	}
+	cfg, err := loadConfig(opts.configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tmp: config: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.theme != "" {
+		cfg.Theme = opts.theme
+	}
+	if opts.seekSmall > 0 {
+		cfg.Playback.SeekStep = opts.seekSmall
+	}
+	if opts.seekLarge > 0 {
+		cfg.Playback.LargeSeekStep = opts.seekLarge
+	}
+	m := model{volume: 100, speed: 100}
+	if err := m.applyConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "tmp: config: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.printConfig {
+		if err := config.Write(os.Stdout, m.effectiveConfig(cfg)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if opts.volume > cfg.Playback.MaxVolume {
+		fmt.Fprintf(os.Stderr, "tmp: --volume %d: must be between 0 and %d\n", opts.volume, cfg.Playback.MaxVolume)
+		os.Exit(2)
+	}
+	tracks, err := startTracks(opts.paths, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tmp: %v\n", err)
+		os.Exit(1)
+	}
+
+	initPath, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
 	}
 	fp := filepicker.New()
-	fp.AllowedTypes = supportedAudioExtensions()
+	fp.AllowedTypes = pickerExtensions()
 	fp.CurrentDirectory = initPath
 
-	var sr beep.SampleRate = 48000
-
-	m := model{
-		tracks:     newTracksComponent(fp),
-		sampleRate: sr,
-		help:       help.NewDefault(),
-		volume:     100,
-		meter:      newAudioMeter(96),
+	m.tracks = newTracksComponent(fp)
+	if sessionPath, err := session.DefaultPath(); err == nil {
+		m.sessionPath = sessionPath
+		m.sessionWriter = &sessionWriter{}
+		m.sessionSaved = time.Now()
+		state, ok, err := session.Load(sessionPath)
+		if err != nil {
+			log.Printf("session: %v", err)
+		} else if ok {
+			m.restoreSession(state)
+		}
+	}
+	m.applyOptions(opts, tracks)
+	if cachePath, err := loudness.DefaultCachePath(); err == nil {
+		if m.loudness, err = loudness.OpenCache(cachePath); err != nil {
+			log.Printf("loudness cache: %v", err)
+		}
+	}
+	if m.loudness == nil {
+		// Scans made ahead of playback still need somewhere to wait.
+		m.loudness, _ = loudness.OpenCache("")
 	}
 
-	speaker.Init(m.sampleRate, m.sampleRate.N(time.Second/10))
+	speaker.Init(m.sampleRate, m.sampleRate.N(cfg.Playback.Buffer))
 
 	p := tea.NewProgram(m)
 	if _, err := p.Run(); err != nil {
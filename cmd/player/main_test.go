@@ -1,8 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -10,10 +19,20 @@ import (
 	"charm.land/bubbles/v2/filepicker"
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/colorprofile"
 	"github.com/charmbracelet/x/ansi"
 	"github.com/gopxl/beep/v2"
+	"github.com/kjloveless/tmp/internal/config"
+	"github.com/kjloveless/tmp/internal/dsp"
 	"github.com/kjloveless/tmp/internal/help"
+	"github.com/kjloveless/tmp/internal/loudness"
+	"github.com/kjloveless/tmp/internal/metadata"
+	"github.com/kjloveless/tmp/internal/session"
+	"github.com/kjloveless/tmp/internal/theme"
 	"github.com/kjloveless/tmp/internal/track"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
 )
 
 type testStream struct {
@@ -53,10 +72,82 @@ func keyPress(text string) tea.KeyPressMsg {
 	return tea.KeyPressMsg(tea.Key{Text: text, Code: runes[0]})
 }
 
+func newHelpUI(t *testing.T, keys help.KeyMap) help.HelpUI {
+	t.Helper()
+	hu, err := help.NewHelpUI(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hu
+}
+
 func keyPressCode(code rune) tea.KeyPressMsg {
 	return tea.KeyPressMsg(tea.Key{Code: code})
 }
 
+// writeFLACFixture encodes a stereo ramp with a short final frame. When
+// knownLength is false the stream info omits the total sample count, as
+// streamed encoders do.
+func writeFLACFixture(t *testing.T, path string, bitsPerSample uint8, samples, blockSize int, knownLength bool) {
+	t.Helper()
+
+	info := &meta.StreamInfo{
+		BlockSizeMin:  uint16(blockSize),
+		BlockSizeMax:  uint16(blockSize),
+		SampleRate:    8000,
+		NChannels:     2,
+		BitsPerSample: bitsPerSample,
+	}
+	var buf bytes.Buffer
+	enc, err := flac.NewEncoder(&buf, info)
+	if err != nil {
+		t.Fatalf("create flac encoder: %v", err)
+	}
+	for start := 0; start < samples; start += blockSize {
+		n := min(blockSize, samples-start)
+		left := make([]int32, n)
+		right := make([]int32, n)
+		for i := range left {
+			left[i] = int32(start + i)
+			right[i] = -int32(start + i)
+		}
+		f := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(n),
+				SampleRate:        info.SampleRate,
+				Channels:          frame.ChannelsLR,
+				BitsPerSample:     bitsPerSample,
+			},
+			Subframes: []*frame.Subframe{
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: left, NSamples: n},
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: right, NSamples: n},
+			},
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatalf("write flac frame: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("close flac encoder: %v", err)
+	}
+
+	data := buf.Bytes()
+	if knownLength {
+		// The encoder only back-fills stream info on seekable writers, so
+		// patch the 36-bit sample count in place.
+		packed := uint64(samples)
+		data[21] = data[21]&0xf0 | byte(packed>>32)&0x0f
+		data[22] = byte(packed >> 24)
+		data[23] = byte(packed >> 16)
+		data[24] = byte(packed >> 8)
+		data[25] = byte(packed)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write flac fixture %s: %v", path, err)
+	}
+}
+
 func loadTracks(t *testing.T, tc *tracksComponent) {
 	t.Helper()
 	cmd := tc.Init()
@@ -822,3 +913,1617 @@ func TestVolumeDownRespondsToUnderscoreKey(t *testing.T) {
 		t.Fatalf("volume = %d, want 90", got.volume)
 	}
 }
+
+func TestPlaySongCmdLoadsFLACTrack(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lossless.flac")
+	writeFLACFixture(t, path, 16, 8000, 1024, true)
+
+	if !isSupportedAudioFile(path) {
+		t.Fatalf("isSupportedAudioFile(%q) = false, want true", path)
+	}
+
+	m := model{}
+	msg := m.playSongCmd(path)()
+	loaded, ok := msg.(loadedTrackMsg)
+	if !ok {
+		t.Fatalf("command returned %T (%v), want loadedTrackMsg", msg, msg)
+	}
+	t.Cleanup(func() {
+		if err := loaded.track.Control.Source.Close(); err != nil {
+			t.Fatalf("close loaded flac track: %v", err)
+		}
+	})
+	if got := loaded.track.Duration(); got != time.Second {
+		t.Fatalf("flac duration = %v, want 1s", got)
+	}
+}
+
+func TestPlaySongCmdDecodesMislabeledFileByHeader(t *testing.T) {
+	data, err := os.ReadFile("../../sounds/mp3/break.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "actually-mp3.wav")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write fixture %s: %v", path, err)
+	}
+
+	m := model{}
+	msg := m.playSongCmd(path)()
+	loaded, ok := msg.(loadedTrackMsg)
+	if !ok {
+		t.Fatalf("command returned %T (%v), want loadedTrackMsg", msg, msg)
+	}
+	t.Cleanup(func() {
+		if err := loaded.track.Control.Source.Close(); err != nil {
+			t.Fatalf("close loaded track: %v", err)
+		}
+	})
+	if loaded.track.Duration() <= 0 {
+		t.Fatalf("duration = %v, want decoded mp3 length", loaded.track.Duration())
+	}
+}
+
+func TestVorbisTrackSeeksAndLoopsCurrent(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSupportedAudioFile(path) {
+		t.Fatalf("isSupportedAudioFile(%q) = false, want true", path)
+	}
+
+	m := model{help: help.NewDefault(), volume: 100}
+	msg := m.playSongCmd(path)()
+	loaded, ok := msg.(loadedTrackMsg)
+	if !ok {
+		t.Fatalf("command returned %T (%v), want loadedTrackMsg", msg, msg)
+	}
+	t.Cleanup(func() {
+		if err := loaded.track.Control.Source.Close(); err != nil {
+			t.Fatalf("close loaded ogg track: %v", err)
+		}
+	})
+	if got := loaded.track.Duration(); got != 500*time.Millisecond {
+		t.Fatalf("ogg duration = %v, want 500ms", got)
+	}
+
+	m.playing = loaded.track
+	m.playingPath = loaded.path
+	if _, err := m.seekBy(250 * time.Millisecond); err != nil {
+		t.Fatalf("seek ogg: %v", err)
+	}
+	if got := m.playing.Control.Source.Position(); got != 11025 {
+		t.Fatalf("position = %d, want 11025 after +250ms seek", got)
+	}
+
+	m.loopMode = loopCurrent
+	if err := m.updatePlaybackLoop(); err != nil {
+		t.Fatalf("loop ogg: %v", err)
+	}
+	samples := make([][2]float64, 16000)
+	if n, ok := m.playing.Control.Stream(samples); !ok || n != len(samples) {
+		t.Fatalf("looped stream = (%d, %v), want (%d, true)", n, ok, len(samples))
+	}
+	if got := m.playing.Control.Source.Position(); got <= 0 || got >= 11025 {
+		t.Fatalf("position = %d, want wrapped into the first half after looping", got)
+	}
+}
+
+func TestPlaySongCmdUsesTagsForTitleAndFallsBackToFileName(t *testing.T) {
+	tests := []struct {
+		path  string
+		title string
+	}{
+		{"../../sounds/mp3/save.mp3", "SaveUpd_673"},
+		{"../../sounds/mp3/break.mp3", "break.mp3"},
+	}
+	for _, tt := range tests {
+		m := model{}
+		msg := m.playSongCmd(tt.path)()
+		loaded, ok := msg.(loadedTrackMsg)
+		if !ok {
+			t.Fatalf("command returned %T (%v), want loadedTrackMsg", msg, msg)
+		}
+		if err := loaded.track.Control.Source.Close(); err != nil {
+			t.Fatalf("close loaded track: %v", err)
+		}
+		if loaded.track.Title != tt.title {
+			t.Fatalf("title = %q, want %q", loaded.track.Title, tt.title)
+		}
+	}
+}
+
+func TestPlayerHelpViewShowsArtworkOnlyWithTrueColor(t *testing.T) {
+	source := &testStream{len: 48000}
+	format := beep.Format{SampleRate: 48000, NumChannels: 2, Precision: 2}
+	cover := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range cover.Pix {
+		cover.Pix[i] = 0xff
+	}
+	m := model{
+		width:   100,
+		help:    help.NewDefault(),
+		volume:  100,
+		playing: track.New(source, &format, "cover.mp3", time.Second),
+		artwork: cover,
+	}
+
+	if got := m.playerHelpView(); strings.Contains(got, "▀") || !strings.Contains(got, "♪") {
+		t.Fatal("art should fall back to the placeholder before a true color profile is known")
+	}
+
+	m.colorProfile = colorprofile.TrueColor
+	got := m.playerHelpView()
+	if !strings.Contains(got, "▀") {
+		t.Fatal("player panel should render the cover with half blocks")
+	}
+	if gotWidth := lipgloss.Width(got); gotWidth != m.width {
+		t.Fatalf("player/help panel width = %d, want %d", gotWidth, m.width)
+	}
+
+	m.width = 46
+	if got := m.playerHelpView(); strings.Contains(got, "▀") || !strings.Contains(got, "♪") {
+		t.Fatalf("narrow panels should show the compact placeholder:\n%s", got)
+	}
+}
+
+func TestTrackSequenceContinuesIntoNextTrackWithinOneBuffer(t *testing.T) {
+	constant := func(value float64, n int) beep.Streamer {
+		return beep.Take(n, beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+			for i := range samples {
+				samples[i] = [2]float64{value, value}
+			}
+			return len(samples), true
+		}))
+	}
+
+	seq := newTrackSequence(constant(1, 3))
+	if !seq.queue(constant(2, 10)) {
+		t.Fatal("queue should accept a next track while the current one plays")
+	}
+	samples := make([][2]float64, 8)
+	if n, ok := seq.Stream(samples); n != len(samples) || !ok {
+		t.Fatalf("Stream() = %d, %v; want a full buffer across the boundary", n, ok)
+	}
+	for i, sample := range samples {
+		want := 2.0
+		if i < 3 {
+			want = 1
+		}
+		if sample[0] != want {
+			t.Fatalf("sample %d = %v, want %v with no gap between tracks", i, sample[0], want)
+		}
+	}
+	if !seq.takeAdvanced() || seq.takeAdvanced() {
+		t.Fatal("the boundary should be reported exactly once")
+	}
+
+	if n, ok := seq.Stream(samples); n != 5 || !ok {
+		t.Fatalf("Stream() = %d, %v; want the remaining 5 samples", n, ok)
+	}
+	if n, ok := seq.Stream(samples); n != 0 || ok {
+		t.Fatalf("Stream() = %d, %v; want the drained sequence to finish", n, ok)
+	}
+	if seq.queue(constant(3, 1)) {
+		t.Fatal("queue should refuse a next track once the sequence has finished")
+	}
+}
+
+func TestGaplessPreloadsQueueHeadAndPromotesAtBoundary(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := loadTrack(path)
+	if err != nil {
+		t.Fatalf("load current track: %v", err)
+	}
+	m := model{
+		help:        help.NewDefault(),
+		volume:      100,
+		sampleRate:  44100,
+		gapless:     true,
+		playing:     current.track,
+		playingPath: path,
+		queue:       []queuedTrack{{path: path, title: "tone.ogg"}},
+	}
+	if err := m.updatePlaybackLoop(); err != nil {
+		t.Fatal(err)
+	}
+	m.sequence = newTrackSequence(m.resampled(m.playing))
+
+	msg := m.preloadNextCmd()()
+	preloaded, ok := msg.(preloadedTrackMsg)
+	if !ok {
+		t.Fatalf("preload returned %T (%v), want preloadedTrackMsg", msg, msg)
+	}
+	if cmd := m.preloadNextCmd(); cmd != nil {
+		t.Fatal("a preload in flight should not be requested twice")
+	}
+	updated, _ := m.Update(preloaded)
+	m = updated.(model)
+	if m.preloaded == nil {
+		t.Fatal("preloaded track should be chained behind the current one")
+	}
+	first := m.playing.Control.Source
+
+	samples := make([][2]float64, current.track.Control.Source.Len()+1000)
+	if n, ok := m.sequence.Stream(samples); n != len(samples) || !ok {
+		t.Fatalf("Stream() = %d, %v; want playback to continue past the boundary", n, ok)
+	}
+	updated, _ = m.Update(tickMsg(time.Now()))
+	m = updated.(model)
+
+	if m.playing.Control.Source == first || m.preloaded != nil {
+		t.Fatal("tick after the boundary should promote the preloaded track")
+	}
+	if len(m.queue) != 0 {
+		t.Fatalf("queue length = %d, want the promoted track dequeued", len(m.queue))
+	}
+	if pos := m.playing.Control.Source.Position(); pos == 0 {
+		t.Fatal("promoted track should already be playing")
+	}
+	if err := closeStream(m.playing.Control.Source); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrackSequenceCrossfadeUsesEqualPowerCurve(t *testing.T) {
+	channel := func(left, right float64) beep.Streamer {
+		return beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+			for i := range samples {
+				samples[i] = [2]float64{left, right}
+			}
+			return len(samples), true
+		})
+	}
+
+	seq := newTrackSequence(channel(1, 0))
+	if !seq.crossfade(channel(0, 1), 8) {
+		t.Fatal("crossfade should start while a track is playing")
+	}
+	samples := make([][2]float64, 12)
+	if n, ok := seq.Stream(samples); n != len(samples) || !ok {
+		t.Fatalf("Stream() = %d, %v; want a full buffer", n, ok)
+	}
+	for i, sample := range samples {
+		out, in := sample[0], sample[1]
+		if power := out*out + in*in; math.Abs(power-1) > 1e-9 {
+			t.Fatalf("sample %d power = %v, want constant 1 across the fade", i, power)
+		}
+		if i > 0 && out > samples[i-1][0] {
+			t.Fatalf("outgoing gain rose at sample %d", i)
+		}
+	}
+	if samples[0][0] != 1 || samples[len(samples)-1][1] != 1 {
+		t.Fatalf("fade should run from the outgoing track to the incoming one, got %v", samples)
+	}
+	if seq.outgoing != nil {
+		t.Fatal("outgoing track should be dropped once the fade completes")
+	}
+}
+
+func TestCrossfadeKeepsOutgoingTrackUntilFadeEnds(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := model{help: help.NewDefault(), volume: 100, sampleRate: 44100}
+	updated, _ := m.Update(keyPress("x"))
+	m = updated.(model)
+	if m.crossfade != 2*time.Second || !strings.Contains(m.playbackMeta(), "xfade 2s") {
+		t.Fatalf("crossfade = %v, meta %q; want 2s shown in the status", m.crossfade, m.playbackMeta())
+	}
+
+	m.playing = first.track
+	m.playingPath = path
+	if err := m.updatePlaybackLoop(); err != nil {
+		t.Fatal(err)
+	}
+	m.sequence = newTrackSequence(m.resampled(m.playing))
+	second.previous = m.playing.Control.Source
+	updated, _ = m.Update(second)
+	m = updated.(model)
+
+	if m.playing.Control.Source != second.track.Control.Source {
+		t.Fatal("incoming track should become the playing track")
+	}
+	if m.fadingOut.Control.Source != first.track.Control.Source || m.sequence.outgoing == nil {
+		t.Fatal("outgoing track should keep playing under the incoming one")
+	}
+
+	// The tone is half a second long, so the fade is at most a quarter second.
+	samples := make([][2]float64, 44100/4+1)
+	if n, ok := m.sequence.Stream(samples); n != len(samples) || !ok {
+		t.Fatalf("Stream() = %d, %v; want a full buffer", n, ok)
+	}
+	updated, _ = m.Update(tickMsg(time.Now()))
+	m = updated.(model)
+	if m.fadingOut.Control.Source != nil {
+		t.Fatal("tick after the fade should release the outgoing track")
+	}
+	if err := closeStream(m.playing.Control.Source); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayGainModeScansUntaggedTracksAndScalesPlayback(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := model{help: help.NewDefault(), volume: 100, playing: plain.track, playingPath: path}
+	if err := m.updatePlaybackLoop(); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, cmd := m.Update(keyPress("r"))
+	m = updated.(model)
+	if m.gainMode != gainTrack || cmd == nil {
+		t.Fatalf("gain mode = %v, cmd = %v; want track mode and a loudness scan", m.gainMode, cmd)
+	}
+	scanned, ok := cmd().(loudnessScannedMsg)
+	if !ok {
+		t.Fatal("scan command should report the measured loudness")
+	}
+	updated, _ = m.Update(scanned)
+	m = updated.(model)
+
+	db, ok := m.trackGainDB(m.playing)
+	if !ok {
+		t.Fatal("scanned track should have a gain in track mode")
+	}
+	if want := -20 * math.Log10(scanned.result.Peak); db > want+1e-9 {
+		t.Fatalf("gain = %.2f dB, want at most %.2f dB so the peak does not clip", db, want)
+	}
+	if meta := m.playbackMeta(); !strings.Contains(meta, fmt.Sprintf("rg track %+.1f dB", db)) {
+		t.Fatalf("playback meta = %q, want the applied track gain", meta)
+	}
+
+	reference, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([][2]float64, 2048)
+	got := make([][2]float64, len(want))
+	reference.track.Control.Source.Stream(want)
+	m.playing.Control.Stream(got)
+	scale := math.Pow(10, db/20)
+	for i := range want {
+		if math.Abs(got[i][0]-want[i][0]*scale) > 1e-9 {
+			t.Fatalf("sample %d = %v, want %v scaled by %.3f", i, got[i][0], want[i][0], scale)
+		}
+	}
+	for _, source := range []beep.StreamSeekCloser{reference.track.Control.Source, m.playing.Control.Source} {
+		if err := closeStream(source); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.gainMode = gainAlbum
+	m.playing.Tags.ReplayGain.AlbumGain, m.playing.Tags.ReplayGain.HasAlbum = -30, true
+	if db, _ := m.trackGainDB(m.playing); db != -30 {
+		t.Fatalf("album gain = %v, want album tag in album mode", db)
+	}
+}
+
+func TestReplayGainScanKeepsPlayingLevelUntilNextPlay(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.track.Tags.ReplayGain.HasTrack {
+		t.Fatal("loadTrack should not scan loudness itself")
+	}
+
+	cache, err := loudness.OpenCache("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := model{help: help.NewDefault(), volume: 100, sampleRate: 44100, gainMode: gainTrack, loudness: cache}
+	updated, cmd := m.Update(loaded)
+	m = updated.(model)
+	if m.playing.Control.Source != loaded.track.Control.Source {
+		t.Fatal("track should start playing before its loudness is known")
+	}
+	var scanned loudnessScannedMsg
+	for _, c := range cmd().(tea.BatchMsg) {
+		if c == nil {
+			continue
+		}
+		if msg, ok := c().(loudnessScannedMsg); ok {
+			scanned = msg
+		}
+	}
+	if scanned.path != path {
+		t.Fatal("loading an untagged track should start a loudness scan")
+	}
+	updated, _ = m.Update(scanned)
+	m = updated.(model)
+	if _, ok := m.trackGainDB(m.playing); ok {
+		t.Fatal("a scan finishing mid-play should not change the playing track's level")
+	}
+
+	again, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, _ = m.Update(again)
+	m = updated.(model)
+	if _, ok := m.trackGainDB(m.playing); !ok {
+		t.Fatal("the cached scan should apply from the track's next play")
+	}
+	if err := closeStream(m.playing.Control.Source); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayGainPrescansNextQueuedTrack(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := loudness.OpenCache("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := beep.Format{SampleRate: 100, NumChannels: 2, Precision: 2}
+	m := model{
+		help:     help.NewDefault(),
+		gainMode: gainTrack,
+		loudness: cache,
+		playing:  track.New(&testStream{len: 100}, &format, "current.mp3", time.Second),
+		queue:    []queuedTrack{{path: path, title: "tone"}},
+	}
+
+	cmd := m.prescanNextCmd()
+	if cmd == nil {
+		t.Fatal("an untagged queue head should be scanned while the current track plays")
+	}
+	if msg, ok := cmd().(loudnessScannedMsg); !ok || msg.path != path {
+		t.Fatalf("prescan message = %#v, want a scan of the queue head", msg)
+	}
+	if _, ok := cache.Get(path); !ok {
+		t.Fatal("prescan result should be cached for the track's load")
+	}
+	if m.prescanNextCmd() != nil {
+		t.Fatal("the queue head should only be scanned once")
+	}
+}
+
+func TestEqualizerKeysCyclePresetsAndEditBands(t *testing.T) {
+	m := model{help: help.NewDefault(), volume: 100, meter: newAudioMeter(32, spectrumFFTSize, spectrumFloorDB)}
+
+	updated, _ := m.Update(keyPress("e"))
+	m = updated.(model)
+	if m.equalizer.Name != "bass boost" {
+		t.Fatalf("preset = %q, want bass boost after one cycle", m.equalizer.Name)
+	}
+	if meta := m.playbackMeta(); !strings.Contains(meta, "eq bass boost") {
+		t.Fatalf("playback meta = %q, want the active preset", meta)
+	}
+
+	// Editor keys are ignored until the editor is open.
+	updated, _ = m.Update(keyPress("."))
+	m = updated.(model)
+	if m.equalizer.Name != "bass boost" {
+		t.Fatalf("preset = %q, want band keys ignored while the editor is closed", m.equalizer.Name)
+	}
+
+	for _, k := range []string{"E", "]", ".", "."} {
+		updated, _ = m.Update(keyPress(k))
+		m = updated.(model)
+	}
+	if m.helpFocus() != help.FocusEqualizer {
+		t.Fatalf("help focus = %q, want equalizer while the editor is open", m.helpFocus())
+	}
+	if got := m.equalizer.Bands[1].Gain; got != dsp.Presets[1].Bands[1].Gain+2 {
+		t.Fatalf("band gain = %v, want the preset gain raised by 2 dB", got)
+	}
+	if m.equalizer.Name != "custom" || dsp.Presets[1].Bands[1].Gain != 5 {
+		t.Fatal("editing should copy the preset into a custom one")
+	}
+
+	view := ansi.Strip(m.spectrogramPanelView(40, 8))
+	if !strings.Contains(view, "Equalizer • custom") || !strings.Contains(view, "▸ 62 Hz +7 dB") {
+		t.Fatalf("editor view = %q, want the preset name and selected band", view)
+	}
+	if !strings.Contains(view, "●") || !strings.Contains(view, "▲") {
+		t.Fatalf("editor view = %q, want the response curve and band marker", view)
+	}
+	if got, want := lipgloss.Height(view), lipgloss.Height(ansi.Strip(model{meter: m.meter}.spectrogramPanelView(40, 8))); got != want {
+		t.Fatalf("editor height = %d, want the sound map height %d", got, want)
+	}
+}
+
+func TestSpeedKeysResamplePlaybackAndShowRate(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStream(loaded.track.Control.Source)
+	m := model{help: help.NewDefault(), volume: 100, speed: 100, playing: loaded.track, playingPath: path}
+	if err := m.updatePlaybackLoop(); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 15 {
+		updated, _ := m.Update(keyPress(">"))
+		m = updated.(model)
+	}
+	if m.speed != maxSpeedPercent || m.playing.Control.Speed != 2 {
+		t.Fatalf("speed = %d%%, control speed = %v; want clamped at 2x", m.speed, m.playing.Control.Speed)
+	}
+	if meta := m.playbackMeta(); !strings.Contains(meta, "speed 2x") {
+		t.Fatalf("playback meta = %q, want the current rate", meta)
+	}
+
+	buf := make([][2]float64, 1024)
+	m.playing.Control.Stream(buf)
+	// The resampler reads ahead of what it has produced in small chunks.
+	if got := m.playing.Control.Source.Position(); got < 2*len(buf) || got >= 3*len(buf) {
+		t.Fatalf("source position = %d after %d samples, want about twice as far at 2x", got, len(buf))
+	}
+	if got, want := m.playing.Remaining(), (m.playing.Duration()-m.playing.Position())/2; got != want {
+		t.Fatalf("remaining = %s, want %s at 2x", got, want)
+	}
+
+	updated, _ := m.Update(keyPress("t"))
+	m = updated.(model)
+	if !m.playing.Control.PreservePitch || !strings.Contains(m.playbackMeta(), "speed 2x pitch lock") {
+		t.Fatalf("playback meta = %q, want pitch lock after toggling", m.playbackMeta())
+	}
+	for range 20 {
+		updated, _ = m.Update(keyPress("<"))
+		m = updated.(model)
+	}
+	if m.speed != minSpeedPercent || m.playing.Control.Speed != 0.5 {
+		t.Fatalf("speed = %d%%, want clamped at 0.5x", m.speed)
+	}
+}
+
+func TestABRepeatLoopsSegmentAndKeepsSeeksInside(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStream(loaded.track.Control.Source)
+	m := model{help: help.NewDefault(), volume: 100, playing: loaded.track, playingPath: path}
+	if err := m.updatePlaybackLoop(); err != nil {
+		t.Fatal(err)
+	}
+	source := m.playing.Control.Source
+	rate := m.playing.Format.SampleRate
+
+	updated, _ := m.Update(keyPress("a"))
+	m = updated.(model)
+	if !m.playing.Repeat.HasA || m.repeatLabel() != "a-b: set B" {
+		t.Fatalf("repeat = %+v, want point A set", m.playing.Repeat)
+	}
+	a := rate.N(m.playing.Repeat.A)
+	if err := source.Seek(a + 10000); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ = m.Update(keyPress("a"))
+	m = updated.(model)
+	if !m.playing.Repeat.Active() || !strings.Contains(m.playbackMeta(), "a-b repeat") {
+		t.Fatalf("repeat = %+v, meta = %q; want an active segment", m.playing.Repeat, m.playbackMeta())
+	}
+	b := rate.N(m.playing.Repeat.B)
+
+	// Playback wraps from B back to A.
+	if err := source.Seek(b - 500); err != nil {
+		t.Fatal(err)
+	}
+	// The decoder may return short reads around the seek.
+	buf := make([][2]float64, 1500)
+	for streamed := 0; streamed < len(buf); {
+		n, ok := m.playing.Control.Stream(buf[:len(buf)-streamed])
+		if !ok {
+			t.Fatal("repeating segment ended")
+		}
+		streamed += n
+	}
+	if got, want := source.Position(), a+1000; got != want {
+		t.Fatalf("position after wrapping = %d, want %d", got, want)
+	}
+
+	// Seeking past B lands on the last sample of the segment, and before A
+	// on A itself.
+	if _, err := m.seekBy(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got := source.Position(); got != b-1 {
+		t.Fatalf("position after seeking ahead = %d, want %d", got, b-1)
+	}
+	if _, err := m.seekBy(-time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got := source.Position(); got != a {
+		t.Fatalf("position after seeking back = %d, want %d", got, a)
+	}
+
+	updated, _ = m.Update(keyPress("a"))
+	m = updated.(model)
+	if m.playing.Repeat != (track.Repeat{}) || m.repeatLabel() != "" {
+		t.Fatalf("repeat = %+v, want cleared on the third press", m.playing.Repeat)
+	}
+}
+
+func TestSeekKeysJumpByPercentStepAndTypedTimestamp(t *testing.T) {
+	source := &testStream{len: 36000, position: 0}
+	format := beep.Format{SampleRate: 10, NumChannels: 2, Precision: 2}
+	m := model{
+		playing:   track.New(source, &format, "talk.mp3", time.Hour),
+		help:      newHelpUI(t, seekKeyMap(help.DefaultKeyMap, 10*time.Second, 90*time.Second)),
+		volume:    100,
+		seekSmall: 10 * time.Second,
+		seekLarge: 90 * time.Second,
+	}
+
+	updated, _ := m.Update(keyPress("7"))
+	m = updated.(model)
+	if source.position != 25200 {
+		t.Fatalf("position = %d, want 70%% of 36000", source.position)
+	}
+
+	updated, _ = m.Update(tea.KeyPressMsg(tea.Key{Code: tea.KeyRight, Mod: tea.ModShift}))
+	m = updated.(model)
+	if source.position != 25200+900 {
+		t.Fatalf("position = %d, want a 90s large step", source.position)
+	}
+	updated, _ = m.Update(keyPressCode(tea.KeyLeft))
+	m = updated.(model)
+	if source.position != 25200+800 {
+		t.Fatalf("position = %d, want a 10s small step back", source.position)
+	}
+	if got := m.help.Keys().Global.SeekAheadLarge.Help().Desc; got != "seek +1m30s" {
+		t.Fatalf("large seek help = %q, want the configured step", got)
+	}
+
+	updated, _ = m.Update(keyPress(":"))
+	m = updated.(model)
+	if !m.prompting {
+		t.Fatal("prompt should open on :")
+	}
+	for _, r := range "12:03.5" {
+		updated, _ = m.Update(keyPress(string(r)))
+		m = updated.(model)
+	}
+	if !strings.Contains(ansi.Strip(m.playerHelpView()), "Go to: 12:03.5") {
+		t.Fatal("status panel should show the prompt while typing")
+	}
+	updated, _ = m.Update(keyPressCode(tea.KeyEnter))
+	m = updated.(model)
+	if m.prompting || m.err != nil || source.position != 7235 {
+		t.Fatalf("prompting = %v, err = %v, position = %d; want a seek to 12:03.5", m.prompting, m.err, source.position)
+	}
+
+	// Esc closes the prompt without quitting or seeking.
+	updated, _ = m.Update(keyPress(":"))
+	m = updated.(model)
+	updated, cmd := m.Update(keyPressCode(tea.KeyEscape))
+	m = updated.(model)
+	if m.prompting || cmd != nil || source.position != 7235 {
+		t.Fatalf("prompting = %v, position = %d; want esc to cancel", m.prompting, source.position)
+	}
+}
+
+// screenPosition finds the cell where text first appears on screen.
+func screenPosition(t *testing.T, screen, text string) (x, y int) {
+	t.Helper()
+	for y, line := range strings.Split(ansi.Strip(screen), "\n") {
+		if i := strings.Index(line, text); i >= 0 {
+			return ansi.StringWidth(line[:i]), y
+		}
+	}
+	t.Fatalf("%q not found on screen:\n%s", text, ansi.Strip(screen))
+	return 0, 0
+}
+
+func TestMouseClicksSelectQueueEntriesAndSeek(t *testing.T) {
+	source := &testStream{len: 1000, position: 0}
+	format := beep.Format{SampleRate: 10, NumChannels: 2, Precision: 2}
+	m := model{
+		playing:     track.New(source, &format, "now.mp3", 100*time.Second),
+		playingPath: "now.mp3",
+		queue: []queuedTrack{
+			{path: "first.mp3", title: "first.mp3"},
+			{path: "second.mp3", title: "second.mp3"},
+			{path: "third.mp3", title: "third.mp3"},
+		},
+		help:   help.NewDefault(),
+		volume: 100,
+		width:  120,
+		height: 40,
+	}
+	if m.View().MouseMode != tea.MouseModeCellMotion {
+		t.Fatal("view should enable mouse reporting")
+	}
+
+	x, y := screenPosition(t, m.render(), "2. second.mp3")
+	updated, cmd := m.Update(tea.MouseClickMsg{X: x, Y: y, Button: tea.MouseLeft})
+	m = updated.(model)
+	if cmd != nil || m.focus != focusQueue || m.queueCursor != 1 {
+		t.Fatalf("focus = %v, cursor = %d; want the clicked queue entry selected", m.focus, m.queueCursor)
+	}
+
+	updated, _ = m.Update(tea.MouseWheelMsg{X: x, Y: y, Button: tea.MouseWheelDown})
+	m = updated.(model)
+	if m.queueCursor != 2 {
+		t.Fatalf("cursor = %d, want the wheel to move the queue selection", m.queueCursor)
+	}
+
+	x, y = screenPosition(t, m.render(), "3. third.mp3")
+	for range 2 {
+		updated, cmd = m.Update(tea.MouseClickMsg{X: x, Y: y, Button: tea.MouseLeft})
+		m = updated.(model)
+	}
+	if cmd == nil || len(m.queue) != 2 || m.queue[1].path != "second.mp3" {
+		t.Fatalf("queue = %+v, want the double-clicked entry taken out to play", m.queue)
+	}
+
+	updated, _ = m.Update(tea.MouseClickMsg{X: 1, Y: 1, Button: tea.MouseLeft})
+	m = updated.(model)
+	if m.focus != focusTracks {
+		t.Fatal("clicking the tracks pane should focus it")
+	}
+
+	// The wheel scrolls the pane under the pointer, not the focused one.
+	cursor := m.queueCursor
+	x, y = screenPosition(t, m.render(), "1. first.mp3")
+	updated, _ = m.Update(tea.MouseWheelMsg{X: x, Y: y, Button: tea.MouseWheelUp})
+	m = updated.(model)
+	if m.focus != focusTracks || m.queueCursor != cursor-1 {
+		t.Fatalf("focus = %v, cursor = %d; want the queue scrolled and the focus kept", m.focus, m.queueCursor)
+	}
+
+	barX, barY, ok := m.progressBarOrigin()
+	if !ok {
+		t.Fatal("progress bar should be clickable while playing")
+	}
+	layout := m.layout()
+	topHeight := lipgloss.Height(layout.top)
+	bottom := strings.Split(ansi.Strip(layout.bottom), "\n")
+	if bar := []rune(bottom[barY]); !strings.ContainsRune("█░", bar[barX]) || !strings.ContainsRune("█░", bar[barX+m.playing.BarWidth()-1]) {
+		t.Fatalf("status row %q should hold the progress bar at column %d", bottom[barY], barX)
+	}
+	updated, _ = m.Update(tea.MouseClickMsg{X: barX + m.playing.BarWidth()/2, Y: topHeight + barY, Button: tea.MouseLeft})
+	m = updated.(model)
+	if want := 1000 * (m.playing.BarWidth()/2*2 + 1) / (2 * m.playing.BarWidth()); source.position != want {
+		t.Fatalf("position = %d, want %d after clicking the middle of the bar", source.position, want)
+	}
+}
+
+func TestQueueReorderKeysMoveSelectedEntry(t *testing.T) {
+	m := model{
+		help:  help.NewDefault(),
+		focus: focusQueue,
+		queue: []queuedTrack{
+			{path: "a.mp3", title: "a.mp3"},
+			{path: "b.mp3", title: "b.mp3"},
+			{path: "c.mp3", title: "c.mp3"},
+			{path: "d.mp3", title: "d.mp3"},
+		},
+		queueCursor: 2,
+	}
+	order := func() string {
+		var paths []string
+		for _, item := range m.queue {
+			paths = append(paths, strings.TrimSuffix(item.path, ".mp3"))
+		}
+		return strings.Join(paths, "")
+	}
+
+	for _, step := range []struct {
+		msg    tea.KeyPressMsg
+		order  string
+		cursor int
+	}{
+		{keyPress("K"), "acbd", 1},
+		{tea.KeyPressMsg(tea.Key{Code: tea.KeyDown, Mod: tea.ModShift}), "abcd", 2},
+		{keyPress("J"), "abdc", 3},
+		{keyPress("J"), "abdc", 3},
+		{keyPress("T"), "cabd", 0},
+		{keyPress("K"), "cabd", 0},
+	} {
+		updated, _ := m.Update(step.msg)
+		m = updated.(model)
+		if order() != step.order || m.queueCursor != step.cursor {
+			t.Fatalf("after %q: order = %s, cursor = %d; want %s, %d", step.msg.String(), order(), m.queueCursor, step.order, step.cursor)
+		}
+	}
+}
+
+func TestQueueNextInsertsAfterPlayingTrack(t *testing.T) {
+	dir := t.TempDir()
+	selectedPath := filepath.Join(dir, "b.mp3")
+	if err := os.WriteFile(selectedPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fp := filepicker.New()
+	fp.CurrentDirectory = dir
+	fp.AllowedTypes = supportedAudioExtensions()
+
+	m := model{
+		help:        help.NewDefault(),
+		tracks:      newTracksComponent(fp),
+		playing:     track.Track{Title: "playing.mp3"},
+		playingPath: filepath.Join(dir, "playing.mp3"),
+		queue: []queuedTrack{
+			{path: "x.mp3", title: "x.mp3"},
+			{path: "y.mp3", title: "y.mp3"},
+		},
+		queueCursor: 1,
+	}
+	loadTracks(t, &m.tracks)
+	m.tracks.setHeight(10)
+
+	updated, _ := m.Update(keyPress("n"))
+	m = updated.(model)
+	if len(m.queue) != 3 || m.queue[0].path != selectedPath {
+		t.Fatalf("queue = %+v, want the selected file first in line", m.queue)
+	}
+	if m.queue[m.queueCursor].path != "y.mp3" {
+		t.Fatalf("cursor on %q, want it to stay on y.mp3", m.queue[m.queueCursor].path)
+	}
+}
+
+func TestShufflePlaysEachTrackOncePerPassAndReshufflesWhenLooping(t *testing.T) {
+	m := model{
+		help:     help.NewDefault(),
+		loopMode: loopQueue,
+		rng:      rand.New(rand.NewPCG(1, 2)),
+	}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		m.enqueueTrack(name, name, metadata.Tags{})
+	}
+	queued := func() string {
+		var paths []string
+		for _, item := range m.queue {
+			paths = append(paths, item.path)
+		}
+		return strings.Join(paths, "")
+	}
+	sorted := func(s string) string {
+		runes := []rune(s)
+		slices.Sort(runes)
+		return string(runes)
+	}
+
+	updated, _ := m.Update(keyPress("s"))
+	m = updated.(model)
+	if !m.shuffle || !strings.Contains(m.playbackMeta(), "shuffle") {
+		t.Fatalf("playback meta = %q, want shuffle on", m.playbackMeta())
+	}
+	firstPass := queued()
+	if sorted(firstPass) != "abcdef" || firstPass == "abcdef" {
+		t.Fatalf("shuffled queue = %s, want a new order of every track", firstPass)
+	}
+
+	// Play two full passes the way finishCurrentTrack does: the finished
+	// track goes back in the queue and the head plays next.
+	var played strings.Builder
+	next, _ := m.dequeueNext()
+	for range 12 {
+		played.WriteString(next.path)
+		m.requeue(next.path, next.title, next.tags)
+		next, _ = m.dequeueNext()
+	}
+	passes := played.String()
+	if passes[:6] != firstPass {
+		t.Fatalf("first pass played %s, want the order shown in the queue %s", passes[:6], firstPass)
+	}
+	if sorted(passes[6:]) != "abcdef" || passes[6:] == firstPass {
+		t.Fatalf("second pass played %s, want every track once in a new order", passes[6:])
+	}
+
+	updated, _ = m.Update(keyPress("s"))
+	m = updated.(model)
+	before := queued()
+	m.enqueueTrack("g", "g", metadata.Tags{})
+	if queued() != before+"g" {
+		t.Fatalf("queue = %s, want tracks appended in order with shuffle off", queued())
+	}
+}
+
+func TestMovingQueueEntriesAcrossShufflePassKeepsOtherEntriesInTheirPass(t *testing.T) {
+	m := model{shuffle: true, passLeft: 3}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		m.queue = append(m.queue, queuedTrack{path: name, title: name})
+	}
+	passes := func() string {
+		var b strings.Builder
+		for i, item := range m.queue {
+			if i == m.passLeft {
+				b.WriteString("|")
+			}
+			b.WriteString(item.path)
+		}
+		return b.String()
+	}
+
+	m.queueCursor = 2
+	for _, step := range []struct {
+		move func() bool
+		want string
+	}{
+		{func() bool { return m.moveSelected(1) }, "ab|dce"},
+		{func() bool { return m.moveSelected(-1) }, "ab|cde"},
+		{func() bool { return m.moveSelected(-1) }, "acb|de"},
+		{func() bool { return m.moveSelected(1) }, "abc|de"},
+		{func() bool { m.queueCursor = 4; return m.moveSelectedToTop() }, "eabc|d"},
+	} {
+		if !step.move() || passes() != step.want {
+			t.Fatalf("queue = %s, want %s", passes(), step.want)
+		}
+	}
+}
+
+func TestPreviousRestartsOrReturnsToHistoryAndNextSkips(t *testing.T) {
+	path, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &testStream{len: 36000, position: 0}
+	format := beep.Format{SampleRate: 10, NumChannels: 2, Precision: 2}
+	m := model{
+		help:        help.NewDefault(),
+		volume:      100,
+		sampleRate:  44100,
+		playing:     track.New(source, &format, "talk.mp3", time.Hour),
+		playingPath: "talk.mp3",
+		history:     []queuedTrack{{path: path, title: "tone.ogg"}},
+	}
+
+	source.position = 100
+	updated, cmd := m.Update(keyPress("b"))
+	m = updated.(model)
+	if source.position != 0 || cmd != nil || len(m.history) != 1 {
+		t.Fatalf("position = %d, history = %+v; want a restart 10s in", source.position, m.history)
+	}
+
+	updated, cmd = m.Update(keyPress("b"))
+	m = updated.(model)
+	if cmd == nil || len(m.history) != 0 {
+		t.Fatalf("history = %+v, want the last entry popped", m.history)
+	}
+	if len(m.queue) != 1 || m.queue[0].path != "talk.mp3" {
+		t.Fatalf("queue = %+v, want the interrupted track back at the head", m.queue)
+	}
+	msg, ok := cmd().(loadedTrackMsg)
+	if !ok || !msg.back || msg.path != path {
+		t.Fatalf("msg = %+v, want the previous track loaded as a step back", msg)
+	}
+	defer closeStream(msg.track.Control.Source)
+	updated, _ = m.Update(msg)
+	m = updated.(model)
+	if m.playingPath != path || len(m.history) != 0 {
+		t.Fatalf("playing %q with history %+v; going back must not record history", m.playingPath, m.history)
+	}
+
+	updated, cmd = m.Update(keyPress("f"))
+	m = updated.(model)
+	if cmd == nil {
+		t.Fatal("next should load the queue head")
+	}
+	next, ok := cmd().(loadedTrackMsg)
+	if ok {
+		defer closeStream(next.track.Control.Source)
+	}
+	if len(m.queue) != 0 {
+		t.Fatalf("queue = %+v, want the head dequeued", m.queue)
+	}
+}
+
+func TestHistoryViewListsPlayedTracksNewestFirst(t *testing.T) {
+	m := model{
+		help:        help.NewDefault(),
+		playing:     track.Track{Title: "c.mp3"},
+		playingPath: "c.mp3",
+		history: []queuedTrack{
+			{path: "a.mp3", title: "a.mp3"},
+			{path: "b.mp3", title: "b.mp3"},
+		},
+	}
+
+	updated, _ := m.Update(keyPressCode(tea.KeyTab))
+	m = updated.(model)
+	if m.focus != focusQueue {
+		t.Fatal("queue pane should be focusable while history is non-empty")
+	}
+	updated, _ = m.Update(keyPress("h"))
+	m = updated.(model)
+	if !m.showHistory {
+		t.Fatal("h should open the history view")
+	}
+	view := m.queueViewWithSize(40, -1)
+	if !strings.Contains(view, "History (2)") || strings.Index(view, "b.mp3") > strings.Index(view, "a.mp3") {
+		t.Fatalf("history view = %q, want the newest entry first", view)
+	}
+
+	m.pushHistory()
+	if got := m.history[len(m.history)-1].path; got != "c.mp3" {
+		t.Fatalf("last history entry = %q, want the playing track", got)
+	}
+	for range maxHistory {
+		m.pushHistory()
+	}
+	if len(m.history) != maxHistory {
+		t.Fatalf("history length = %d, want it capped at %d", len(m.history), maxHistory)
+	}
+}
+
+func TestQueueDirectoryEnqueuesAudioFilesRecursivelyInNaturalOrder(t *testing.T) {
+	root := t.TempDir()
+	album := filepath.Join(root, "album")
+	for _, name := range []string{
+		"album/10 outro.mp3",
+		"album/2 intro.mp3",
+		"album/cover.jpg",
+		"album/Disc 10/1 last.flac",
+		"album/disc 2/1 first.flac",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fp := filepicker.New()
+	fp.CurrentDirectory = root
+	fp.AllowedTypes = supportedAudioExtensions()
+	fp.DirAllowed = true
+
+	m := model{help: help.NewDefault(), tracks: newTracksComponent(fp)}
+	loadTracks(t, &m.tracks)
+	m.tracks.setHeight(10)
+
+	updated, cmd := m.Update(keyPress("Q"))
+	m = updated.(model)
+	if cmd == nil {
+		t.Fatal("Q on a directory should start a scan")
+	}
+	msg, ok := cmd().(directoryScannedMsg)
+	if !ok || msg.dir != album {
+		t.Fatalf("msg = %+v, want a scan of %s", msg, album)
+	}
+	updated, _ = m.Update(msg)
+	m = updated.(model)
+
+	var got []string
+	for _, queued := range m.queue {
+		rel, err := filepath.Rel(album, queued.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"2 intro.mp3", "10 outro.mp3", "disc 2/1 first.flac", "Disc 10/1 last.flac"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("queue = %q, want %q", got, want)
+	}
+	if meta := m.playbackMeta(); !strings.Contains(meta, "+4 from album") {
+		t.Fatalf("playback meta = %q, want the number of queued tracks", meta)
+	}
+	updated, _ = m.Update(keyPress("?"))
+	m = updated.(model)
+	if strings.Contains(m.playbackMeta(), "from album") {
+		t.Fatal("the notice should clear on the next key press")
+	}
+}
+
+func TestSortTracksOrdersByTrackNumberBeforeName(t *testing.T) {
+	tracks := []queuedTrack{
+		{path: "a/b.mp3"},
+		{path: "a/a.mp3"},
+		{path: "a/c.mp3", tags: metadata.Tags{Track: 2}},
+		{path: "a/d.mp3", tags: metadata.Tags{Track: 1}},
+	}
+	sortTracks(tracks)
+	var got []string
+	for _, track := range tracks {
+		got = append(got, track.path)
+	}
+	if want := []string{"a/d.mp3", "a/c.mp3", "a/a.mp3", "a/b.mp3"}; !slices.Equal(got, want) {
+		t.Fatalf("order = %q, want %q", got, want)
+	}
+}
+
+func TestPlaylistOpensIntoQueueAndQueueSavesAsM3U8(t *testing.T) {
+	tone, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	list := "#EXTM3U\n#EXTINF:1,Tone\n" + tone + "\nmissing.mp3\n"
+	if err := os.WriteFile(filepath.Join(dir, "mix.m3u"), []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fp := filepicker.New()
+	fp.CurrentDirectory = dir
+	fp.AllowedTypes = pickerExtensions()
+
+	m := model{help: help.NewDefault(), tracks: newTracksComponent(fp)}
+	loadTracks(t, &m.tracks)
+	m.tracks.setHeight(10)
+
+	updated, cmd := m.Update(keyPress("q"))
+	m = updated.(model)
+	if cmd == nil {
+		t.Fatal("q on a playlist should load it")
+	}
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+	if len(m.queue) != 1 || m.queue[0].path != tone || m.queue[0].title != "Tone" {
+		t.Fatalf("queue = %+v, want the existing entry with its playlist title", m.queue)
+	}
+	if meta := m.playbackMeta(); !strings.Contains(meta, "+1 from mix.m3u, skipped 1 missing") {
+		t.Fatalf("playback meta = %q, want a warning about the missing entry", meta)
+	}
+
+	updated, _ = m.Update(keyPress("w"))
+	m = updated.(model)
+	if !m.prompting {
+		t.Fatal("w should prompt for a playlist name")
+	}
+	for _, r := range "saved" {
+		updated, _ = m.Update(keyPress(string(r)))
+		m = updated.(model)
+	}
+	updated, cmd = m.Update(keyPressCode(tea.KeyEnter))
+	m = updated.(model)
+	if cmd == nil || m.err != nil {
+		t.Fatalf("err = %v, want a save in progress", m.err)
+	}
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "saved.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seconds := int(probeDuration(tone).Round(time.Second) / time.Second)
+	if want := fmt.Sprintf("#EXTM3U\n#EXTINF:%d,Tone\n%s\n", seconds, tone); string(data) != want {
+		t.Fatalf("saved playlist = %q, want %q", data, want)
+	}
+	if meta := m.playbackMeta(); !strings.Contains(meta, "saved 1 to saved.m3u8") {
+		t.Fatalf("playback meta = %q, want a confirmation", meta)
+	}
+
+	// Saving over an existing playlist asks first.
+	answer := func(text string) tea.Cmd {
+		for _, r := range text {
+			updated, _ = m.Update(keyPress(string(r)))
+			m = updated.(model)
+		}
+		updated, cmd = m.Update(keyPressCode(tea.KeyEnter))
+		m = updated.(model)
+		return cmd
+	}
+	updated, _ = m.Update(keyPress("w"))
+	m = updated.(model)
+	if cmd := answer("mix.m3u"); !m.prompting || m.promptKind != promptOverwritePlaylist || cmd == nil {
+		t.Fatal("saving to an existing file should ask before overwriting it")
+	}
+	if cmd := answer("n"); cmd != nil || m.prompting {
+		t.Fatal("declining should not save")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "mix.m3u")); string(data) != list {
+		t.Fatalf("declined overwrite changed the playlist to %q", data)
+	}
+	if meta := m.playbackMeta(); !strings.Contains(meta, "kept mix.m3u") {
+		t.Fatalf("playback meta = %q, want the kept file named", meta)
+	}
+
+	updated, _ = m.Update(keyPress("w"))
+	m = updated.(model)
+	answer("mix.m3u")
+	cmd = answer("y")
+	if cmd == nil {
+		t.Fatal("confirming should save")
+	}
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+	if data, _ := os.ReadFile(filepath.Join(dir, "mix.m3u")); !strings.Contains(string(data), "#EXTINF:") || strings.Contains(string(data), "missing.mp3") {
+		t.Fatalf("confirmed overwrite wrote %q, want the queue", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("dir has %d entries, want no temporary files left behind", len(entries))
+	}
+}
+
+func TestPlayNextOnPlaylistQueuesItAtHeadInOrder(t *testing.T) {
+	tone, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	list := "#EXTM3U\n#EXTINF:1,A\n" + tone + "\n#EXTINF:1,B\n" + tone + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "mix.m3u"), []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fp := filepicker.New()
+	fp.CurrentDirectory = dir
+	fp.AllowedTypes = pickerExtensions()
+
+	m := model{help: help.NewDefault(), tracks: newTracksComponent(fp), queue: []queuedTrack{{path: "x.mp3", title: "X"}}}
+	loadTracks(t, &m.tracks)
+	m.tracks.setHeight(10)
+
+	updated, cmd := m.Update(keyPress("n"))
+	m = updated.(model)
+	if cmd == nil {
+		t.Fatal("n on a playlist should load it")
+	}
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+	var titles []string
+	for _, item := range m.queue {
+		titles = append(titles, item.title)
+	}
+	if strings.Join(titles, ",") != "A,B,X" {
+		t.Fatalf("queue = %v, want the playlist ahead of the existing entry", titles)
+	}
+	if meta := m.playbackMeta(); !strings.Contains(meta, "+2 next from mix.m3u") {
+		t.Fatalf("playback meta = %q, want the tracks reported as queued next", meta)
+	}
+}
+
+func TestSessionRestoresStateResumesPausedAndSavesOnQuit(t *testing.T) {
+	tone, err := filepath.Abs("testdata/tone.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	fp := filepicker.New()
+	fp.AllowedTypes = pickerExtensions()
+	position := probeDuration(tone) / 2
+
+	m := model{
+		help:          help.NewDefault(),
+		tracks:        newTracksComponent(fp),
+		volume:        100,
+		speed:         100,
+		sampleRate:    44100,
+		sessionPath:   filepath.Join(dir, "session.json"),
+		sessionWriter: &sessionWriter{},
+	}
+	m.restoreSession(session.State{
+		Directory: dir,
+		Volume:    400,
+		Muted:     true,
+		Loop:      "queue",
+		Playing:   tone,
+		Position:  position,
+		Queue: []session.Entry{
+			{Path: tone, Title: "Tone"},
+			{Path: filepath.Join(dir, "gone.mp3"), Title: "Gone"},
+		},
+	})
+	if m.tracks.picker.CurrentDirectory != dir || m.volume != maxVolumePercent || !m.muted || m.loopMode != loopQueue {
+		t.Fatalf("restored dir %q, volume %d, muted %v, loop %s", m.tracks.picker.CurrentDirectory, m.volume, m.muted, m.loopMode)
+	}
+	if len(m.queue) != 1 || m.queue[0].title != "Tone" {
+		t.Fatalf("queue = %+v, want the missing entry dropped", m.queue)
+	}
+
+	msg, ok := m.resumeCmd()().(loadedTrackMsg)
+	if !ok || !msg.resume {
+		t.Fatalf("resume produced %+v, want a resumed track", msg)
+	}
+	updated, _ := m.Update(msg)
+	m = updated.(model)
+	if !m.playing.Control.Paused || m.playingPath != tone {
+		t.Fatalf("playing %q paused=%v, want the last track resumed paused", m.playingPath, m.playing.Control.Paused)
+	}
+	if got := m.playing.Position(); got < position-time.Millisecond || got > position+time.Millisecond {
+		t.Fatalf("position = %s, want %s", got, position)
+	}
+	if len(m.history) != 0 {
+		t.Fatalf("history = %+v, resuming should not record a play", m.history)
+	}
+
+	updated, _ = m.Update(keyPressCode(tea.KeyEscape))
+	m = updated.(model)
+	saved, ok, err := session.Load(m.sessionPath)
+	if err != nil || !ok {
+		t.Fatalf("Load() = %v, %v; want the session saved on quit", ok, err)
+	}
+	if saved.Playing != tone || saved.Volume != maxVolumePercent || !saved.Muted || saved.Loop != "queue" ||
+		saved.Directory != dir || len(saved.Queue) != 1 {
+		t.Fatalf("saved = %+v", saved)
+	}
+	if saved.Position < position-time.Millisecond || saved.Position > position+time.Millisecond {
+		t.Fatalf("saved position = %s, want %s", saved.Position, position)
+	}
+}
+
+func TestSessionSavesPeriodicallyWhilePlaying(t *testing.T) {
+	m := model{sessionPath: filepath.Join(t.TempDir(), "session.json"), sessionWriter: &sessionWriter{}, sessionSaved: time.Now()}
+	if cmd := m.saveSessionDueCmd(); cmd != nil {
+		t.Fatal("a save right after the last one should wait")
+	}
+	m.sessionSaved = time.Now().Add(-sessionSaveInterval)
+	cmd := m.saveSessionDueCmd()
+	if cmd == nil {
+		t.Fatal("a save should be due after the interval")
+	}
+	if msg := cmd().(sessionSavedMsg); msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	if _, ok, err := session.Load(m.sessionPath); !ok || err != nil {
+		t.Fatalf("Load() = %v, %v; want the periodic save on disk", ok, err)
+	}
+}
+
+func TestSessionRestoreReadsQueuedTags(t *testing.T) {
+	frame := append([]byte("TPE1\x00\x00\x00\x07\x00\x00\x00"), "Artist"...)
+	header := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, byte(len(frame))}
+	path := filepath.Join(t.TempDir(), "song.mp3")
+	if err := os.WriteFile(path, append(header, frame...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var m model
+	m.restoreSession(session.State{Queue: []session.Entry{{Path: path, Title: "Song"}}})
+	if len(m.queue) != 1 || m.queue[0].tags.Artist != "Artist" {
+		t.Fatalf("queue = %+v, want the entry's tags read again", m.queue)
+	}
+}
+
+func TestSessionSaveOnQuitWinsOverLatePeriodicSave(t *testing.T) {
+	m := model{sessionPath: filepath.Join(t.TempDir(), "session.json"), sessionWriter: &sessionWriter{}, volume: 40}
+	periodic := m.saveSessionDueCmd()
+	if periodic == nil {
+		t.Fatal("a save should be due")
+	}
+
+	m.volume = 70
+	if err := m.saveSession(); err != nil {
+		t.Fatal(err)
+	}
+	if msg := periodic().(sessionSavedMsg); msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	saved, _, err := session.Load(m.sessionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Volume != 70 {
+		t.Fatalf("saved volume = %d, want the newer snapshot kept", saved.Volume)
+	}
+}
+
+func TestParseArgsAcceptsDirectoryFilesAndFlagsInAnyOrder(t *testing.T) {
+	dir := t.TempDir()
+	song := filepath.Join(dir, "song.mp3")
+	list := filepath.Join(dir, "mix.m3u8")
+	for _, path := range []string{song, list, filepath.Join(dir, "notes.txt")} {
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts, err := parseArgs([]string{"--volume", "40", dir, song, "--loop", "queue", list, "--shuffle", "--theme", "light", "--seek-step", "10s", "--large-seek-step", "2m"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.dir != dir || !slices.Equal(opts.paths, []string{song, list}) ||
+		opts.volume != 40 || opts.loop != "queue" || !opts.shuffle || opts.theme != "light" ||
+		opts.seekSmall != 10*time.Second || opts.seekLarge != 2*time.Minute {
+		t.Fatalf("opts = %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		{"--volume", "-1"},
+		{"--loop", "forever"},
+		{"--seek-step", "0s"},
+		{"--large-seek-step", "soon"},
+		{filepath.Join(dir, "notes.txt")},
+		{filepath.Join(dir, "missing.mp3")},
+		{dir, dir},
+		{"--bogus"},
+	} {
+		if _, err := parseArgs(args, io.Discard); err == nil {
+			t.Errorf("parseArgs(%q) should fail", args)
+		}
+	}
+
+	var usage strings.Builder
+	if _, err := parseArgs([]string{"--help"}, &usage); !errors.Is(err, flag.ErrHelp) || !strings.Contains(usage.String(), "-volume percent") {
+		t.Fatalf("--help = %v, usage %q", err, usage.String())
+	}
+}
+
+func TestParseArgsTakesEverythingAfterDashDashAsPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for _, name := range []string{"-odd.mp3", "--shuffle.mp3"} {
+		if err := os.WriteFile(name, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts, err := parseArgs([]string{"--volume", "40", "--", "-odd.mp3", "--shuffle.mp3"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "-odd.mp3"), filepath.Join(dir, "--shuffle.mp3")}
+	if !slices.Equal(opts.paths, want) || opts.volume != 40 || opts.shuffle {
+		t.Fatalf("opts = %+v, want %q as files", opts, want)
+	}
+}
+
+func TestApplyOptionsQueuesArgumentsAheadOfRestoredQueueAndStartsFirst(t *testing.T) {
+	m := model{
+		volume:     100,
+		queue:      []queuedTrack{{path: "restored.mp3", title: "restored.mp3"}},
+		resumePath: "last.mp3",
+	}
+	m.applyOptions(options{dir: "/music", volume: 30, loop: "current"}, []queuedTrack{
+		{path: "a.mp3", title: "a.mp3"},
+		{path: "b.mp3", title: "b.mp3"},
+	})
+	if m.tracks.picker.CurrentDirectory != "/music" || m.volume != 30 || m.loopMode != loopCurrent {
+		t.Fatalf("dir %q, volume %d, loop %s", m.tracks.picker.CurrentDirectory, m.volume, m.loopMode)
+	}
+	if m.startPath != "a.mp3" || m.resumePath != "" {
+		t.Fatalf("start %q, resume %q; want the first argument to start", m.startPath, m.resumePath)
+	}
+	if len(m.queue) != 2 || m.queue[0].path != "b.mp3" || m.queue[1].path != "restored.mp3" {
+		t.Fatalf("queue = %+v", m.queue)
+	}
+
+	m = model{volume: 70, loopMode: loopQueue}
+	m.tracks.picker.CurrentDirectory = "/restored"
+	m.applyOptions(options{volume: -1}, nil)
+	if m.volume != 70 || m.loopMode != loopQueue || m.startPath != "" ||
+		m.tracks.picker.CurrentDirectory != "/restored" {
+		t.Fatal("unset options should keep the restored settings")
+	}
+}
+
+func TestConfigOverridesVolumeStepLimitAndSpectrum(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Playback.VolumeStep = 25
+	cfg.Playback.MaxVolume = 200
+	cfg.Playback.SeekStep = 15 * time.Second
+	cfg.Playback.SampleRate = 44100
+	cfg.Spectrum.FFTSize = 2048
+	cfg.Spectrum.FloorDB = -90
+
+	m := model{volume: 100}
+	if err := m.applyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if m.sampleRate != 44100 || m.meter.fftSize != 2048 || m.meter.floorDB != -90 {
+		t.Fatalf("sample rate %d, meter %+v", m.sampleRate, m.meter)
+	}
+	if got := m.help.Keys().Global.SeekAhead.Help().Desc; got != "seek +15s" {
+		t.Fatalf("seek help = %q, want the configured step", got)
+	}
+	for range 5 {
+		updated, _ := m.Update(keyPress("="))
+		m = updated.(model)
+	}
+	if m.volume != 200 {
+		t.Fatalf("volume = %d, want 25%% steps clamped at the configured 200%%", m.volume)
+	}
+	updated, _ := m.Update(keyPress("-"))
+	m = updated.(model)
+	if m.volume != 175 {
+		t.Fatalf("volume = %d, want one 25%% step down", m.volume)
+	}
+}
+
+func TestConfigThemeColorsPanelsSpectrumAndHelp(t *testing.T) {
+	t.Cleanup(func() { theme.Use(theme.Default()) })
+	cfg := defaultConfig()
+	cfg.Theme = "high-contrast"
+	m := model{volume: 100}
+	if err := m.applyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	palette, _ := theme.Builtin("high-contrast")
+	if got := trackPanelStyle(true).GetBorderTopForeground(); got != lipgloss.Color(palette.Focus) {
+		t.Fatalf("focused border = %v, want %s", got, palette.Focus)
+	}
+	if got := queuePanelStyle(false, 20).GetBorderTopForeground(); got != lipgloss.Color(palette.Border) {
+		t.Fatalf("queue border = %v, want %s", got, palette.Border)
+	}
+	if got := help.DefaultStyles().Key.GetForeground(); got != lipgloss.Color(palette.Focus) {
+		t.Fatalf("help key color = %v, want %s", got, palette.Focus)
+	}
+	for _, tc := range []struct {
+		level float64
+		want  string
+	}{
+		{0.1, palette.Muted},
+		{0.3, palette.Spectrum[0]},
+		{0.9, palette.Spectrum[4]},
+	} {
+		if got := spectrogramColor(tc.level); got != tc.want {
+			t.Errorf("spectrogramColor(%g) = %s, want %s", tc.level, got, tc.want)
+		}
+	}
+
+	cfg.Theme = "solarized"
+	if err := m.applyConfig(cfg); err == nil || !strings.HasPrefix(err.Error(), `theme: unknown theme "solarized"`) {
+		t.Fatalf("applyConfig() error = %v, want the unknown theme named", err)
+	}
+}
+
+func TestConfigRemapsKeysAndReportsConflicts(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Keys.Global = map[string]config.KeyList{
+		"volume_up":    {"u"},
+		"jump_percent": {"!", "@", "#"},
+	}
+	source := &testStream{len: 1000}
+	format := beep.Format{SampleRate: 10, NumChannels: 2, Precision: 2}
+	m := model{volume: 50, playing: track.New(source, &format, "talk.mp3", 100*time.Second)}
+	if err := m.applyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, _ := m.Update(keyPress("u"))
+	m = updated.(model)
+	if m.volume != 60 {
+		t.Fatalf("volume = %d, want the remapped key to raise it", m.volume)
+	}
+	updated, _ = m.Update(keyPress("="))
+	m = updated.(model)
+	if m.volume != 60 {
+		t.Fatalf("volume = %d, the replaced key should do nothing", m.volume)
+	}
+	updated, _ = m.Update(keyPress("#"))
+	m = updated.(model)
+	if source.position != 200 {
+		t.Fatalf("position = %d, want the third jump key to go to 20%%", source.position)
+	}
+	if got := m.help.Keys().Global.VolumeUp.Help(); got.Key != "u" || got.Desc != "volume up" {
+		t.Fatalf("volume up help = %+v, want it labelled with the remapped key", got)
+	}
+
+	cfg.Keys.Tracks = map[string]config.KeyList{"queue_next": {"m"}}
+	if err := m.applyConfig(cfg); err == nil || !strings.Contains(err.Error(), `key "m" is bound twice in tracks: mute and play next`) {
+		t.Fatalf("applyConfig() error = %v, want the conflict with mute", err)
+	}
+	cfg.Keys.Tracks = map[string]config.KeyList{"enqueue": {"z"}}
+	if err := m.applyConfig(cfg); err == nil || err.Error() != "keys.tracks.enqueue: unknown action" {
+		t.Fatalf("applyConfig() error = %v, want the unknown action named", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+)
+
+const doubleClickInterval = 400 * time.Millisecond
+
+// queueClick remembers the last clicked queue entry to detect double-clicks.
+type queueClick struct {
+	index int
+	at    time.Time
+}
+
+func (m model) updateMouseClick(mouse tea.Mouse) (tea.Model, tea.Cmd) {
+	if mouse.Button != tea.MouseLeft || m.help.GetshowHelp() || m.prompting {
+		return m, nil
+	}
+
+	layout := m.layout()
+	topHeight := lipgloss.Height(layout.top)
+	switch {
+	case mouse.Y >= topHeight:
+		return m.clickStatus(mouse.X, mouse.Y-topHeight)
+	case mouse.X < layout.leftWidth:
+		m.focus = focusTracks
+	case mouse.X >= layout.rightX && mouse.Y < layout.queueHeight:
+		return m.clickQueue(mouse.Y-queuePanelStyle(false, 0).GetBorderTopSize(), layout)
+	}
+	return m, nil
+}
+
+// clickQueue focuses the queue and selects the clicked entry; a second click
+// on the same entry plays it.
+func (m model) clickQueue(row int, layout screenLayout) (tea.Model, tea.Cmd) {
+	if m.queuePaneEmpty() {
+		return m, nil
+	}
+	index := m.queueItemAt(row, layout.queueWidth, layout.queueContentHeight)
+	m.focus = focusQueue
+	if index < 0 {
+		m.clampQueueCursor()
+		return m, nil
+	}
+
+	now := time.Now()
+	double := m.lastClick.index == index && now.Sub(m.lastClick.at) < doubleClickInterval
+	m.queueCursor = index
+	m.lastClick = queueClick{index: index, at: now}
+	if !double {
+		return m, nil
+	}
+	m.lastClick = queueClick{index: -1}
+	selected, ok := m.dequeueSelected()
+	if !ok {
+		return m, nil
+	}
+	return m, m.playSongCmd(selected.path)
+}
+
+// progressBarOrigin is where the progress bar starts inside the status
+// panel, mirroring the layout of playerHelpView.
+func (m model) progressBarOrigin() (x, y int, ok bool) {
+	if m.err != nil || m.playing.Title == "" || m.playing.Format == nil {
+		return 0, 0, false
+	}
+	rows, progressRow := 3, 1
+	if m.playing.Tags.Details() != "" {
+		rows, progressRow = 4, 2
+	}
+	_, artWidth := m.artworkView(rows, m.playerHelpContentWidth())
+	style := playerHelpPanelStyle()
+	// The status lines have one column of padding of their own.
+	x = style.GetBorderLeftSize() + style.GetPaddingLeft() + artWidth + 1
+	y = style.GetBorderTopSize() + style.GetPaddingTop() + progressRow
+	return x, y, true
+}
+
+// clickStatus seeks when the click lands on the progress bar.
+func (m model) clickStatus(x, y int) (tea.Model, tea.Cmd) {
+	barX, barY, ok := m.progressBarOrigin()
+	width := m.playing.BarWidth()
+	if !ok || y != barY || x < barX || x >= barX+width {
+		return m, nil
+	}
+	cmd, err := m.seekPercent((float64(x-barX) + 0.5) / float64(width))
+	if err != nil {
+		m.err = err
+	} else {
+		m.err = nil
+	}
+	return m, cmd
+}
+
+// updateMouseWheel scrolls the pane under the pointer, hit-tested the same
+// way as clicks, without moving the focus.
+func (m model) updateMouseWheel(mouse tea.Mouse) (tea.Model, tea.Cmd) {
+	if m.help.GetshowHelp() || m.prompting {
+		return m, nil
+	}
+	var delta int
+	switch mouse.Button {
+	case tea.MouseWheelUp:
+		delta = -1
+	case tea.MouseWheelDown:
+		delta = 1
+	default:
+		return m, nil
+	}
+
+	layout := m.layout()
+	switch {
+	case mouse.Y >= lipgloss.Height(layout.top):
+		return m, nil
+	case mouse.X < layout.leftWidth:
+		code := tea.KeyDown
+		if delta < 0 {
+			code = tea.KeyUp
+		}
+		m.syncTracksViewportHeight()
+		cmd, _, _ := m.tracks.Update(tea.KeyPressMsg{Code: code})
+		return m, cmd
+	case mouse.X >= layout.rightX && mouse.Y < layout.queueHeight:
+		if m.showHistory {
+			// The history is listed newest first.
+			m.moveHistoryCursor(-delta)
+		} else {
+			m.moveQueueCursor(delta)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/kjloveless/tmp/internal/decode"
+	"github.com/kjloveless/tmp/internal/metadata"
+	"github.com/kjloveless/tmp/internal/playlist"
+)
+
+type (
+	playlistLoadedMsg struct {
+		path    string
+		tracks  []queuedTrack
+		missing int
+		next    bool // queue the tracks right after the playing one
+		err     error
+	}
+	playlistSavedMsg struct {
+		path  string
+		count int
+		err   error
+	}
+)
+
+// pickerExtensions lists what the tracks pane shows: audio files and
+// playlists.
+func pickerExtensions() []string {
+	return append(supportedAudioExtensions(), playlist.Extensions()...)
+}
+
+// loadPlaylistCmd reads a playlist in the background. Entries that are
+// missing or not playable are counted and skipped.
+func loadPlaylistCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := playlist.Load(path)
+		if err != nil {
+			return playlistLoadedMsg{path: path, err: err}
+		}
+		msg := playlistLoadedMsg{path: path}
+		for _, entry := range entries {
+			info, err := os.Stat(entry.Path)
+			if err != nil || info.IsDir() || !isSupportedAudioFile(entry.Path) {
+				msg.missing++
+				continue
+			}
+			fallback := entry.Title
+			if fallback == "" {
+				fallback = filepath.Base(entry.Path)
+			}
+			tags, _ := metadata.Read(entry.Path)
+			msg.tracks = append(msg.tracks, queuedTrack{
+				path:  entry.Path,
+				title: tags.DisplayTitle(fallback),
+				tags:  tags,
+			})
+		}
+		return msg
+	}
+}
+
+// openSelectedPlaylistCmd loads the highlighted playlist at the end of the
+// queue, or at its head when next is set.
+func (m model) openSelectedPlaylistCmd(next bool) tea.Cmd {
+	path, ok := m.tracks.selectedFilePath()
+	if !ok || !playlist.Supported(path) {
+		return nil
+	}
+	load := loadPlaylistCmd(path)
+	return func() tea.Msg {
+		msg := load().(playlistLoadedMsg)
+		msg.next = next
+		return msg
+	}
+}
+
+func (m *model) acceptPlaylist(msg playlistLoadedMsg) error {
+	if msg.err != nil {
+		return msg.err
+	}
+	queued := 0
+	if msg.next {
+		// Inserting at the head in reverse keeps the playlist's order.
+		for _, t := range slices.Backward(msg.tracks) {
+			if m.enqueueNext(t.path, t.title, t.tags) {
+				queued++
+			}
+		}
+		m.notice = fmt.Sprintf("+%d next from %s", queued, filepath.Base(msg.path))
+	} else {
+		for _, t := range msg.tracks {
+			if m.enqueueTrack(t.path, t.title, t.tags) {
+				queued++
+			}
+		}
+		m.notice = fmt.Sprintf("+%d from %s", queued, filepath.Base(msg.path))
+	}
+	if msg.missing > 0 {
+		m.notice += fmt.Sprintf(", skipped %d missing", msg.missing)
+	}
+	return nil
+}
+
+func (m *model) openSavePlaylistPrompt() tea.Cmd {
+	return m.openPrompt(promptSavePlaylist, "Save playlist: ", "queue.m3u8")
+}
+
+// playlistEntries is the playing track followed by the queue. Queued tracks
+// have not been decoded yet, so their durations are left unknown.
+func (m model) playlistEntries() []playlist.Entry {
+	var entries []playlist.Entry
+	if m.playingPath != "" {
+		entries = append(entries, playlist.Entry{
+			Path:     m.playingPath,
+			Title:    m.playing.Title,
+			Duration: m.playing.Duration(),
+		})
+	}
+	for _, queued := range m.queue {
+		entries = append(entries, playlist.Entry{Path: queued.path, Title: queued.title, Duration: -1})
+	}
+	return entries
+}
+
+// submitSavePlaylist resolves the typed name against the directory shown in
+// the tracks pane, defaulting to the .m3u8 extension. An existing file is
+// only replaced once the overwrite prompt is answered with y.
+func (m *model) submitSavePlaylist(name string) (tea.Cmd, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("playlist name is empty")
+	}
+	entries := m.playlistEntries()
+	if len(entries) == 0 {
+		return nil, errors.New("nothing to save: the queue is empty")
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.tracks.picker.CurrentDirectory, path)
+	}
+	if filepath.Ext(path) == "" {
+		path += ".m3u8"
+	}
+	if _, err := os.Stat(path); err == nil {
+		m.savePath = path
+		return m.openPrompt(promptOverwritePlaylist, fmt.Sprintf("%s exists, overwrite? [y/N] ", filepath.Base(path)), ""), nil
+	}
+	return savePlaylistCmd(path, entries), nil
+}
+
+func (m *model) submitOverwritePlaylist(answer string) tea.Cmd {
+	path := m.savePath
+	m.savePath = ""
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return savePlaylistCmd(path, m.playlistEntries())
+	}
+	m.notice = "kept " + filepath.Base(path)
+	return nil
+}
+
+func savePlaylistCmd(path string, entries []playlist.Entry) tea.Cmd {
+	return func() tea.Msg {
+		for i := range entries {
+			if entries[i].Duration < 0 {
+				entries[i].Duration = probeDuration(entries[i].Path)
+			}
+		}
+		err := playlist.Save(path, entries)
+		return playlistSavedMsg{path: path, count: len(entries), err: err}
+	}
+}
+
+// probeDuration decodes just enough of a file to learn its length, or
+// returns -1.
+func probeDuration(path string) time.Duration {
+	stream, format, err := decode.File(path)
+	if err != nil {
+		return -1
+	}
+	defer stream.Close()
+	return format.SampleRate.D(stream.Len())
+}
+
+func (m *model) acceptSavedPlaylist(msg playlistSavedMsg) error {
+	if msg.err != nil {
+		return msg.err
+	}
+	m.notice = fmt.Sprintf("saved %d to %s", msg.count, filepath.Base(msg.path))
+	return nil
+}
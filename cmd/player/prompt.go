@@ -0,0 +1,56 @@
+package main
+
+import (
+	"charm.land/bubbles/v2/textinput"
+	tea "charm.land/bubbletea/v2"
+)
+
+type promptKind int
+
+const (
+	promptSeek promptKind = iota
+	promptSavePlaylist
+	promptOverwritePlaylist
+)
+
+func (m *model) openPrompt(kind promptKind, label, placeholder string) tea.Cmd {
+	m.prompt = textinput.New()
+	m.prompt.Prompt = label
+	m.prompt.Placeholder = placeholder
+	m.promptKind = kind
+	m.prompting = true
+	return m.prompt.Focus()
+}
+
+// updatePrompt owns the keyboard while a prompt is open: enter submits the
+// typed value and esc cancels.
+func (m model) updatePrompt(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.prompting = false
+		return m, nil
+	case "enter":
+		m.prompting = false
+		var (
+			cmd tea.Cmd
+			err error
+		)
+		switch m.promptKind {
+		case promptSeek:
+			cmd, err = m.submitSeek(m.prompt.Value())
+		case promptSavePlaylist:
+			cmd, err = m.submitSavePlaylist(m.prompt.Value())
+		case promptOverwritePlaylist:
+			cmd = m.submitOverwritePlaylist(m.prompt.Value())
+		}
+		if err != nil {
+			m.err = err
+		} else {
+			m.err = nil
+		}
+		return m, cmd
+	}
+	var cmd tea.Cmd
+	m.prompt, cmd = m.prompt.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/kjloveless/tmp/internal/loudness"
+	"github.com/kjloveless/tmp/internal/track"
+)
+
+type gainMode int
+
+const (
+	gainOff gainMode = iota
+	gainTrack
+	gainAlbum
+)
+
+func (gm gainMode) next() gainMode {
+	switch gm {
+	case gainOff:
+		return gainTrack
+	case gainTrack:
+		return gainAlbum
+	default:
+		return gainOff
+	}
+}
+
+func (gm gainMode) String() string {
+	switch gm {
+	case gainTrack:
+		return "track"
+	case gainAlbum:
+		return "album"
+	default:
+		return "off"
+	}
+}
+
+type loudnessScannedMsg struct {
+	path   string
+	result loudness.Result
+	// now applies result to the playing track at once. Only scans started
+	// by switching normalization on do, since that changes the level anyway.
+	now bool
+}
+
+// scanLoudnessCmd measures t in the background when normalization is on and
+// its file has no ReplayGain track tags; acceptLoudness applies the result.
+func (m model) scanLoudnessCmd(t track.Track, path string, now bool) tea.Cmd {
+	if m.gainMode == gainOff || path == "" || t.Tags.ReplayGain.HasTrack {
+		return nil
+	}
+	cache := m.loudness
+	return func() tea.Msg {
+		result, err := loudness.ScanFile(path, cache)
+		if err != nil {
+			return errorMsg(fmt.Errorf("loudness scan: %w", err))
+		}
+		return loudnessScannedMsg{path: path, result: result, now: now}
+	}
+}
+
+// prescanNextCmd measures the next queued track while the current one plays,
+// so an untagged file starts at its normalized level instead of jumping to
+// it partway through.
+func (m *model) prescanNextCmd() tea.Cmd {
+	if m.gainMode == gainOff || !m.isPlaying() {
+		return nil
+	}
+	next, ok := m.peekNext()
+	if !ok || next.path == m.prescanned {
+		return nil
+	}
+	m.prescanned = next.path
+	return m.scanLoudnessCmd(track.Track{Tags: next.tags}, next.path, false)
+}
+
+// applyCachedLoudness fills in the gain of an untagged track that has been
+// scanned before.
+func (m model) applyCachedLoudness(t *track.Track, path string) {
+	if m.loudness == nil || t.Tags.ReplayGain.HasTrack {
+		return
+	}
+	if result, ok := m.loudness.Get(path); ok {
+		applyLoudness(t, result)
+	}
+}
+
+func applyLoudness(t *track.Track, result loudness.Result) {
+	t.Tags.ReplayGain.TrackGain = result.Gain()
+	t.Tags.ReplayGain.TrackPeak = result.Peak
+	t.Tags.ReplayGain.HasTrack = true
+}
+
+// trackGainDB returns the gain in dB applied to t under the current mode.
+// Album mode falls back to the track gain for tracks without album tags, and
+// the gain is limited so that a known peak does not clip.
+func (m model) trackGainDB(t track.Track) (float64, bool) {
+	rg := t.Tags.ReplayGain
+	var db, peak float64
+	switch {
+	case m.gainMode == gainOff:
+		return 0, false
+	case m.gainMode == gainAlbum && rg.HasAlbum:
+		db, peak = rg.AlbumGain, rg.AlbumPeak
+	case rg.HasTrack:
+		db, peak = rg.TrackGain, rg.TrackPeak
+	default:
+		return 0, false
+	}
+	if peak > 0 {
+		db = min(db, -20*math.Log10(peak))
+	}
+	return db, true
+}
+
+func (m model) gainLabel() string {
+	label := fmt.Sprintf("rg %s", m.gainMode)
+	if db, ok := m.trackGainDB(m.playing); ok {
+		label += fmt.Sprintf(" %+.1f dB", db)
+	}
+	return label
+}
+
+func (m *model) toggleGainMode() (tea.Cmd, error) {
+	m.gainMode = m.gainMode.next()
+	// A preload was prepared for the old mode; the next tick redoes it.
+	m.discardPreloaded()
+	m.prescanned = ""
+	m.applyCachedLoudness(&m.playing, m.playingPath)
+	if err := m.updatePlaybackLoop(); err != nil {
+		return nil, err
+	}
+	if !m.isPlaying() {
+		return nil, nil
+	}
+	return m.scanLoudnessCmd(m.playing, m.playingPath, true), nil
+}
+
+// acceptLoudness applies a finished scan to the preloaded track it was run
+// for. The playing track keeps the level it started at unless the scan asked
+// otherwise; the cached result applies from its next play.
+func (m *model) acceptLoudness(msg loudnessScannedMsg) error {
+	var t *track.Track
+	switch {
+	case msg.now && msg.path == m.playingPath:
+		t = &m.playing
+	case m.preloaded != nil && msg.path == m.preloaded.path:
+		t = &m.preloaded.track
+	}
+	if t == nil || t.Tags.ReplayGain.HasTrack {
+		return nil
+	}
+	applyLoudness(t, msg.result)
+	return m.updatePlaybackLoop()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/kjloveless/tmp/internal/help"
+	"github.com/kjloveless/tmp/internal/track"
+)
+
+func (m model) smallSeekStep() time.Duration {
+	if m.seekSmall <= 0 {
+		return seekStep
+	}
+	return m.seekSmall
+}
+
+func (m model) largeSeekStep() time.Duration {
+	if m.seekLarge <= 0 {
+		return largeSeekStep
+	}
+	return m.seekLarge
+}
+
+// formatStep prints a seek step the way it reads in help text: 5s, 1m, 1m30s.
+func formatStep(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+// seekKeyMap labels the seek bindings with the configured steps.
+func seekKeyMap(keys help.KeyMap, small, large time.Duration) help.KeyMap {
+	keys.Global.SeekBack.SetHelp(keys.Global.SeekBack.Help().Key, "seek -"+formatStep(small))
+	keys.Global.SeekAhead.SetHelp(keys.Global.SeekAhead.Help().Key, "seek +"+formatStep(small))
+	keys.Global.SeekBackLarge.SetHelp(keys.Global.SeekBackLarge.Help().Key, "seek -"+formatStep(large))
+	keys.Global.SeekAheadLarge.SetHelp(keys.Global.SeekAheadLarge.Help().Key, "seek +"+formatStep(large))
+	return keys
+}
+
+// jumpPercent maps the jump keys, 0-9 by default, to 0%-90% of the track
+// by their position in the binding, so remapped keys keep working.
+func jumpPercent(binding key.Binding, pressed string) float64 {
+	index := slices.Index(binding.Keys(), pressed)
+	return float64(max(index, 0)) / 10
+}
+
+func (m *model) openSeekPrompt() tea.Cmd {
+	if !m.canSeek() {
+		return nil
+	}
+	return m.openPrompt(promptSeek, "Go to: ", "1:23:45.500")
+}
+
+func (m *model) submitSeek(value string) (tea.Cmd, error) {
+	position, err := track.ParseDuration(value)
+	if err != nil {
+		return nil, err
+	}
+	return m.seekTo(position)
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/gopxl/beep/v2"
+	"github.com/gopxl/beep/v2/speaker"
+
+	"github.com/kjloveless/tmp/internal/track"
+)
+
+type preloadedTrackMsg loadedTrackMsg
+
+// trackSequence is the streamer handed to the speaker. It plays the current
+// track and, when one is queued, switches to the next track on the audio
+// thread in the middle of the buffer the current track runs out in, so queued
+// tracks follow each other without a gap. During a crossfade it also mixes
+// the outgoing track under the current one. Fields are guarded by the speaker
+// lock.
+type trackSequence struct {
+	current  beep.Streamer
+	next     beep.Streamer
+	advanced bool
+
+	outgoing beep.Streamer
+	fadeAt   int
+	fadeLen  int
+	mix      [][2]float64
+}
+
+func newTrackSequence(current beep.Streamer) *trackSequence {
+	return &trackSequence{current: current}
+}
+
+func (s *trackSequence) Stream(samples [][2]float64) (int, bool) {
+	n, ok := s.streamCurrent(samples)
+	if s.outgoing == nil {
+		return n, ok
+	}
+
+	if cap(s.mix) < len(samples) {
+		s.mix = make([][2]float64, len(samples))
+	}
+	mix := s.mix[:len(samples)]
+	faded, fadeOK := s.outgoing.Stream(mix)
+	for i := range samples {
+		// Equal-power curve: the gains' squares always sum to one.
+		progress := min(float64(s.fadeAt+i)/float64(s.fadeLen), 1)
+		in, out := math.Sin(progress*math.Pi/2), math.Cos(progress*math.Pi/2)
+
+		var incoming, outgoing [2]float64
+		if i < n {
+			incoming = samples[i]
+		}
+		if i < faded {
+			outgoing = mix[i]
+		}
+		samples[i][0] = incoming[0]*in + outgoing[0]*out
+		samples[i][1] = incoming[1]*in + outgoing[1]*out
+	}
+	s.fadeAt += len(samples)
+	if !fadeOK || faded < len(samples) || s.fadeAt >= s.fadeLen {
+		s.outgoing = nil
+	}
+	return len(samples), true
+}
+
+func (s *trackSequence) streamCurrent(samples [][2]float64) (int, bool) {
+	filled := 0
+	for filled < len(samples) && s.current != nil {
+		n, ok := s.current.Stream(samples[filled:])
+		filled += n
+		if ok {
+			if n == 0 {
+				break
+			}
+			continue
+		}
+		s.current, s.next = s.next, nil
+		if s.current != nil {
+			s.advanced = true
+		}
+	}
+	if filled == 0 && s.current == nil {
+		return 0, false
+	}
+	return filled, true
+}
+
+func (s *trackSequence) Err() error {
+	return nil
+}
+
+// queue sets the track to continue with once the current one ends. It fails
+// when the sequence has already run dry.
+func (s *trackSequence) queue(next beep.Streamer) bool {
+	if s.current == nil {
+		return false
+	}
+	s.next = next
+	return true
+}
+
+// crossfade makes incoming the current track and fades the previous current
+// track out over length samples. A fade already in progress is cut. It fails
+// when the sequence has already run dry.
+func (s *trackSequence) crossfade(incoming beep.Streamer, length int) bool {
+	if s.current == nil && s.outgoing == nil {
+		return false
+	}
+	s.outgoing = s.current
+	s.current = incoming
+	s.next = nil
+	s.fadeAt, s.fadeLen = 0, max(length, 1)
+	return true
+}
+
+func (s *trackSequence) takeAdvanced() bool {
+	advanced := s.advanced
+	s.advanced = false
+	return advanced
+}
+
+func (m *model) resampled(t track.Track) beep.Streamer {
+	return beep.Resample(4, t.Format.SampleRate, m.sampleRate, t.Control.Ctrl)
+}
+
+// preloadNextCmd decodes the head of the queue ahead of time while gapless
+// playback is on. A preload that no longer matches the queue head is dropped
+// first.
+func (m *model) preloadNextCmd() tea.Cmd {
+	if !m.gapless || m.crossfade > 0 || !m.isPlaying() || m.sequence == nil || m.repeatsCurrent() {
+		return nil
+	}
+	next, ok := m.peekNext()
+	if !ok {
+		m.discardPreloaded()
+		return nil
+	}
+	if m.preloaded != nil {
+		if m.preloaded.path == next.path {
+			return nil
+		}
+		m.discardPreloaded()
+	}
+	if m.preloading == next.path {
+		return nil
+	}
+
+	m.preloading = next.path
+	path := next.path
+	return func() tea.Msg {
+		msg, err := loadTrack(path)
+		if err != nil {
+			return errorMsg(err)
+		}
+		return preloadedTrackMsg(msg)
+	}
+}
+
+// acceptPreloaded chains a decoded track behind the current one if it is
+// still the next track to play.
+func (m *model) acceptPreloaded(msg loadedTrackMsg) error {
+	if m.preloading == msg.path {
+		m.preloading = ""
+	}
+	next, ok := m.peekNext()
+	if !m.gapless || !m.isPlaying() || m.sequence == nil || m.preloaded != nil || !ok || next.path != msg.path {
+		return closeStream(msg.track.Control.Source)
+	}
+
+	if err := m.configurePlayback(&msg.track); err != nil {
+		_ = closeStream(msg.track.Control.Source)
+		return err
+	}
+	streamer := m.resampled(msg.track)
+	speaker.Lock()
+	queued := m.sequence.queue(streamer)
+	speaker.Unlock()
+	if !queued {
+		return closeStream(msg.track.Control.Source)
+	}
+	m.preloaded = &msg
+	return nil
+}
+
+// promotePreloaded makes the preloaded track the playing one once the audio
+// thread has switched to it, mirroring finishCurrentTrack.
+func (m *model) promotePreloaded() error {
+	if m.preloaded == nil || m.sequence == nil {
+		return nil
+	}
+	speaker.Lock()
+	advanced := m.sequence.takeAdvanced()
+	speaker.Unlock()
+	if !advanced {
+		return nil
+	}
+
+	if m.loopMode == loopQueue {
+		m.requeue(m.playingPath, m.playing.Title, m.playing.Tags)
+	}
+	m.dequeueNext()
+	if err := closeStream(m.playing.Control.Source); err != nil {
+		log.Printf("error closing previous track: %v", err)
+	}
+
+	next := m.preloaded
+	m.preloaded = nil
+	m.pushHistory()
+	m.playing = next.track
+	m.playingPath = next.path
+	m.artwork = next.artwork
+	m.err = nil
+	return nil
+}
+
+// discardPreloaded drops a preloaded track that will not play, unless the
+// audio thread already switched to it.
+func (m *model) discardPreloaded() {
+	if m.preloaded != nil && m.sequence != nil {
+		speaker.Lock()
+		advanced := m.sequence.advanced
+		speaker.Unlock()
+		if advanced {
+			_ = m.promotePreloaded()
+			return
+		}
+	}
+	m.releasePreloaded()
+}
+
+// releasePreloaded unchains and closes the preloaded track. Callers that
+// replace playback outright use it after clearing the speaker.
+func (m *model) releasePreloaded() {
+	m.preloading = ""
+	if m.preloaded == nil {
+		return
+	}
+	if m.sequence != nil {
+		speaker.Lock()
+		m.sequence.next = nil
+		speaker.Unlock()
+	}
+	if err := closeStream(m.preloaded.track.Control.Source); err != nil {
+		log.Printf("error closing preloaded track: %v", err)
+	}
+	m.preloaded = nil
+}
+
+// crossfadeSteps are the durations the crossfade key cycles through.
+var crossfadeSteps = []time.Duration{0, 2 * time.Second, 5 * time.Second, 10 * time.Second}
+
+func nextCrossfade(current time.Duration) time.Duration {
+	for i, step := range crossfadeSteps {
+		if step > current {
+			return crossfadeSteps[i]
+		}
+	}
+	return crossfadeSteps[0]
+}
+
+// crossfadeDue reports whether the playing track is close enough to its end
+// to start fading into the next queued one.
+func (m *model) crossfadeDue() bool {
+	if m.crossfade <= 0 || m.transitioning {
+		return false
+	}
+	if _, ok := m.peekNext(); !ok && m.loopMode != loopQueue {
+		return false
+	}
+	return m.playing.Remaining() <= m.fadeWindow(m.playing)
+}
+
+// fadeWindow is the configured crossfade, shortened so that short tracks
+// still play their first half unmixed.
+func (m *model) fadeWindow(t track.Track) time.Duration {
+	return min(m.crossfade, t.Duration()/2)
+}
+
+// fadeLength is how long outgoing takes to fade out. A fade never outlasts
+// what is left of a track that does not loop.
+func (m *model) fadeLength(outgoing track.Track) time.Duration {
+	length := m.fadeWindow(outgoing)
+	if !outgoing.Control.Loop {
+		length = min(length, outgoing.Remaining())
+	}
+	return length
+}
+
+// startCrossfade mixes next in over the playing track. It fails when the
+// sequence has already run dry and a fresh one has to be started instead.
+func (m *model) startCrossfade(next track.Track) bool {
+	if m.sequence == nil {
+		return false
+	}
+	incoming := m.resampled(next)
+	length := m.sampleRate.N(m.fadeLength(m.playing))
+	speaker.Lock()
+	defer speaker.Unlock()
+	return m.sequence.crossfade(incoming, length)
+}
+
+// finishFadeOut closes the outgoing track once its fade has ended, or right
+// away when playback was cut.
+func (m *model) finishFadeOut() {
+	if m.fadingOut.Control.Source == nil {
+		return
+	}
+	if m.sequence != nil {
+		speaker.Lock()
+		fading := m.sequence.outgoing != nil
+		speaker.Unlock()
+		if fading {
+			return
+		}
+	}
+	if err := closeStream(m.fadingOut.Control.Source); err != nil {
+		log.Printf("error closing faded track: %v", err)
+	}
+	m.fadingOut = track.Track{}
+}
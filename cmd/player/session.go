@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/kjloveless/tmp/internal/metadata"
+	"github.com/kjloveless/tmp/internal/session"
+)
+
+// sessionSaveInterval is how often the session is saved while playing, so a
+// crash loses little more than this much progress.
+const sessionSaveInterval = 30 * time.Second
+
+type sessionSavedMsg struct {
+	err error
+}
+
+// sessionWriter serializes session saves, so a background save that finishes
+// after a newer one, such as the save on quit, does not replace it.
+type sessionWriter struct {
+	mu      sync.Mutex
+	written uint64 // generation of the newest snapshot on disk
+}
+
+func (w *sessionWriter) save(path string, generation uint64, state session.State) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if generation <= w.written {
+		return nil
+	}
+	w.written = generation
+	return session.Save(path, state)
+}
+
+func (m model) sessionState() session.State {
+	state := session.State{
+		Directory: m.tracks.picker.CurrentDirectory,
+		Volume:    m.volume,
+		Muted:     m.muted,
+		Loop:      m.loopMode.String(),
+	}
+	if m.playingPath != "" {
+		state.Playing = m.playingPath
+		state.Position = m.playing.Position()
+	} else if m.resumePath != "" {
+		// Quitting before the restored track finished loading keeps it.
+		state.Playing, state.Position = m.resumePath, m.resumeAt
+	}
+	for _, queued := range m.queue {
+		state.Queue = append(state.Queue, session.Entry{Path: queued.path, Title: queued.title})
+	}
+	return state
+}
+
+// restoreSession applies saved state, dropping files that have gone missing
+// since. The last track is resumed by Init.
+func (m *model) restoreSession(state session.State) {
+	if info, err := os.Stat(state.Directory); err == nil && info.IsDir() {
+		m.tracks.picker.CurrentDirectory = state.Directory
+	}
+	m.volume = max(0, min(state.Volume, m.maxVolume()))
+	m.muted = state.Muted
+	m.loopMode, _ = loopModeByName(state.Loop)
+	for _, entry := range state.Queue {
+		if !fileExists(entry.Path) {
+			continue
+		}
+		// Tags are not saved; reading them again shows any edits since.
+		tags, _ := metadata.Read(entry.Path)
+		m.insertQueued(len(m.queue), entry.Path, entry.Title, tags)
+	}
+	if fileExists(state.Playing) {
+		m.resumePath, m.resumeAt = state.Playing, max(0, state.Position)
+	}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// resumeCmd loads the restored track paused at its saved position.
+func (m model) resumeCmd() tea.Cmd {
+	if m.resumePath == "" {
+		return nil
+	}
+	path, position := m.resumePath, m.resumeAt
+	return func() tea.Msg {
+		msg, err := loadTrack(path)
+		if err != nil {
+			return errorMsg(err)
+		}
+		source := msg.track.Control.Source
+		target := min(msg.track.Format.SampleRate.N(position), max(source.Len()-1, 0))
+		if err := source.Seek(target); err != nil {
+			_ = closeStream(source)
+			return errorMsg(err)
+		}
+		msg.resume = true
+		msg.back = true
+		return msg
+	}
+}
+
+func (m *model) saveSession() error {
+	if m.sessionPath == "" {
+		return nil
+	}
+	m.sessionSaved = time.Now()
+	m.sessionGen++
+	return m.sessionWriter.save(m.sessionPath, m.sessionGen, m.sessionState())
+}
+
+// saveSessionDueCmd saves in the background once sessionSaveInterval has
+// passed since the last save.
+func (m *model) saveSessionDueCmd() tea.Cmd {
+	if m.sessionPath == "" || time.Since(m.sessionSaved) < sessionSaveInterval {
+		return nil
+	}
+	m.sessionSaved = time.Now()
+	m.sessionGen++
+	writer, path, generation, state := m.sessionWriter, m.sessionPath, m.sessionGen, m.sessionState()
+	return func() tea.Msg {
+		return sessionSavedMsg{err: writer.save(path, generation, state)}
+	}
+}
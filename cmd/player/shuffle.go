@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math/rand/v2"
+
+	"github.com/kjloveless/tmp/internal/metadata"
+)
+
+func (m *model) randIntN(n int) int {
+	if m.rng != nil {
+		return m.rng.IntN(n)
+	}
+	return rand.IntN(n)
+}
+
+// toggleShuffle reorders the queue itself, so the queue view always shows
+// the order tracks will play in. passLeft counts the entries at the head of
+// the queue that belong to the current pass; with queue looping, finished
+// tracks are placed at random among the entries after them and become the
+// next pass once the current one runs out.
+func (m *model) toggleShuffle() {
+	m.shuffle = !m.shuffle
+	if !m.shuffle {
+		m.passLeft = 0
+		return
+	}
+	for i := len(m.queue) - 1; i > 0; i-- {
+		j := m.randIntN(i + 1)
+		m.queue[i], m.queue[j] = m.queue[j], m.queue[i]
+	}
+	m.passLeft = len(m.queue)
+}
+
+// shuffleIndex picks where a newly queued track goes: somewhere in the
+// current pass, or in the next one for tracks coming around again.
+func (m *model) shuffleIndex(nextPass bool) int {
+	if nextPass {
+		return m.passLeft + m.randIntN(len(m.queue)-m.passLeft+1)
+	}
+	return m.randIntN(m.passLeft + 1)
+}
+
+// insertCurrentPass queues a track to play within the current pass.
+func (m *model) insertCurrentPass(index int, path, title string, tags metadata.Tags) bool {
+	if !m.insertQueued(index, path, title, tags) {
+		return false
+	}
+	if m.shuffle {
+		m.passLeft++
+	}
+	return true
+}
+
+// requeue puts a finished track back for the next pass of a looping queue.
+func (m *model) requeue(path, title string, tags metadata.Tags) bool {
+	if !m.shuffle {
+		return m.enqueueTrack(path, title, tags)
+	}
+	return m.insertQueued(m.shuffleIndex(true), path, title, tags)
+}
+
+// startPass makes the whole queue the current pass once the previous pass
+// has been played through.
+func (m *model) startPass() {
+	if m.shuffle && m.passLeft == 0 {
+		m.passLeft = len(m.queue)
+	}
+}
+
+// removedFromQueue keeps the pass boundary in step with a removed entry.
+func (m *model) removedFromQueue(index int) {
+	if index < m.passLeft {
+		m.passLeft--
+	}
+}
+
+// movedInQueue keeps the pass boundary in step with an entry moved from one
+// index to another. An entry dragged across the boundary joins the pass it
+// lands in; the entries it passes keep theirs.
+func (m *model) movedInQueue(from, to int) {
+	m.removedFromQueue(from)
+	if to < m.passLeft || (to == m.passLeft && from < to) {
+		m.passLeft++
+	}
+}
@@ -0,0 +1,135 @@
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kjloveless/tmp/internal/metadata"
+)
+
+// thumbnailSize bounds the decoded image kept in memory. The player panel
+// never shows more than a few dozen half-block cells, so larger covers are
+// downscaled once at load time instead of on every frame.
+const thumbnailSize = 64
+
+var ErrNotFound = errors.New("no artwork found")
+
+// folderImages are checked, case-insensitively, next to the track when it
+// has no embedded picture.
+var folderImages = []string{
+	"cover.jpg", "cover.jpeg", "cover.png",
+	"folder.jpg", "folder.jpeg", "folder.png",
+	"front.jpg", "front.jpeg", "front.png",
+	"album.jpg", "album.png",
+}
+
+// Load returns the cover art for the track at path: an embedded picture if
+// there is one, otherwise a folder image in the same directory.
+func Load(path string) (image.Image, error) {
+	data, err := metadata.ReadPicture(path)
+	if err == nil && len(data) > 0 {
+		if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+			return Thumbnail(img, thumbnailSize), nil
+		}
+	}
+
+	coverPath, err := folderImage(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(coverPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", filepath.Base(coverPath), err)
+	}
+	return Thumbnail(img, thumbnailSize), nil
+}
+
+func folderImage(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	names := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names[strings.ToLower(entry.Name())] = entry.Name()
+		}
+	}
+	for _, candidate := range folderImages {
+		if name, ok := names[candidate]; ok {
+			return filepath.Join(dir, name), nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Thumbnail box-filters img so that neither side exceeds size pixels.
+// Images that already fit are returned unchanged.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	tw, th := fit(w, h, size, size)
+	return scale(img, tw, th)
+}
+
+// fit returns the largest size with the aspect ratio of w×h that fits in
+// maxW×maxH, never smaller than one pixel per side.
+func fit(w, h, maxW, maxH int) (int, int) {
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+	tw, th := maxW, h*maxW/w
+	if th > maxH {
+		tw, th = w*maxH/h, maxH
+	}
+	return max(tw, 1), max(th, 1)
+}
+
+// scale resamples img to w×h by averaging the source pixels that fall into
+// each target pixel.
+func scale(img image.Image, w, h int) *image.RGBA {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := bounds.Min.Y + y*sh/h
+		y1 := max(bounds.Min.Y+(y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := bounds.Min.X + x*sw/w
+			x1 := max(bounds.Min.X+(x+1)*sw/w, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			out.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return out
+}
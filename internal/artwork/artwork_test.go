@@ -0,0 +1,79 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func solidImage(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestLoadFallsBackToFolderImage(t *testing.T) {
+	dir := t.TempDir()
+	track := filepath.Join(dir, "song.wav")
+	if err := os.WriteFile(track, []byte("RIFF\x04\x00\x00\x00WAVE"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(track); err != ErrNotFound {
+		t.Fatalf("Load without art = %v, want ErrNotFound", err)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "Folder.PNG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, solidImage(300, 150, color.RGBA{R: 200, A: 255})); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	img, err := Load(track)
+	if err != nil {
+		t.Fatalf("load folder image: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(thumbnailSize, thumbnailSize/2) {
+		t.Fatalf("thumbnail size = %v, want %dx%d", got, thumbnailSize, thumbnailSize/2)
+	}
+}
+
+func TestRenderUsesHalfBlocksAndFitsBox(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 2))
+	img.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+	img.SetRGBA(0, 1, color.RGBA{B: 255, A: 255})
+
+	got := Render(img, 3, 1)
+	if !strings.Contains(got, "\x1b[38;2;255;0;0m\x1b[48;2;0;0;255m▀") {
+		t.Fatalf("render should paint the top pixel as foreground and the bottom as background: %q", got)
+	}
+	if plain := ansi.Strip(got); plain != " ▀ " {
+		t.Fatalf("render = %q, want the pixel centered in a 3-cell row", plain)
+	}
+}
+
+func TestPlaceholderFillsBox(t *testing.T) {
+	for _, size := range []struct{ width, height int }{{8, 4}, {2, 4}} {
+		lines := strings.Split(Placeholder(size.width, size.height), "\n")
+		if len(lines) != size.height {
+			t.Fatalf("placeholder %dx%d has %d lines", size.width, size.height, len(lines))
+		}
+		for _, line := range lines {
+			if w := ansi.StringWidth(line); w != size.width {
+				t.Fatalf("placeholder %dx%d line %q has width %d", size.width, size.height, line, w)
+			}
+		}
+	}
+}
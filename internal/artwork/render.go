@@ -0,0 +1,94 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+const (
+	upperHalfBlock = "▀"
+	resetStyle     = "\x1b[0m"
+)
+
+// Render draws img in a width×height cell box using upper half blocks: the
+// foreground color paints the top pixel of each cell and the background the
+// bottom one, so a cell holds two square-ish pixels. The image keeps its
+// aspect ratio and is centered; unused cells are blank.
+func Render(img image.Image, width, height int) string {
+	if img == nil || width <= 0 || height <= 0 {
+		return ""
+	}
+	bounds := img.Bounds()
+	pw, ph := fit(bounds.Dx(), bounds.Dy(), width, height*2)
+	if pw == 0 || ph == 0 {
+		return Placeholder(width, height)
+	}
+	scaled := scale(img, pw, ph)
+
+	left := (width - pw) / 2
+	top := (height*2 - ph) / 2
+	pixel := func(x, y int) (color.RGBA, bool) {
+		x, y = x-left, y-top
+		if x < 0 || y < 0 || x >= pw || y >= ph {
+			return color.RGBA{}, false
+		}
+		return scaled.RGBAAt(x, y), true
+	}
+
+	var b strings.Builder
+	for row := 0; row < height; row++ {
+		if row > 0 {
+			b.WriteByte('\n')
+		}
+		for col := 0; col < width; col++ {
+			upper, hasUpper := pixel(col, row*2)
+			lower, hasLower := pixel(col, row*2+1)
+			switch {
+			case hasUpper && hasLower:
+				fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm%s",
+					upper.R, upper.G, upper.B, lower.R, lower.G, lower.B, upperHalfBlock)
+			case hasUpper:
+				fmt.Fprintf(&b, "%s\x1b[38;2;%d;%d;%dm%s", resetStyle, upper.R, upper.G, upper.B, upperHalfBlock)
+			case hasLower:
+				fmt.Fprintf(&b, "%s\x1b[38;2;%d;%d;%dm▄", resetStyle, lower.R, lower.G, lower.B)
+			default:
+				b.WriteString(resetStyle + " ")
+			}
+		}
+		b.WriteString(resetStyle)
+	}
+	return b.String()
+}
+
+// Placeholder fills a width×height cell box with a plain note glyph framed by
+// box-drawing characters, for tracks without art or terminals that cannot
+// show it. Boxes too small for a frame show only the glyph.
+func Placeholder(width, height int) string {
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+	const glyph = "♪"
+
+	lines := make([]string, height)
+	if width < 3 || height < 3 {
+		for i := range lines {
+			lines[i] = strings.Repeat(" ", width)
+		}
+		middle := height / 2
+		lines[middle] = strings.Repeat(" ", (width-1)/2) + glyph + strings.Repeat(" ", width-1-(width-1)/2)
+		return strings.Join(lines, "\n")
+	}
+
+	inner := width - 2
+	lines[0] = "╭" + strings.Repeat("─", inner) + "╮"
+	lines[height-1] = "╰" + strings.Repeat("─", inner) + "╯"
+	for i := 1; i < height-1; i++ {
+		lines[i] = "│" + strings.Repeat(" ", inner) + "│"
+	}
+	middle := height / 2
+	pad := (inner - 1) / 2
+	lines[middle] = "│" + strings.Repeat(" ", pad) + glyph + strings.Repeat(" ", inner-1-pad) + "│"
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config holds the user-tunable settings. Load starts from the defaults the
+// caller passes in, so a file only needs the keys it changes.
+type Config struct {
+	// Theme is a built-in theme name or the path of a theme file, relative
+	// to the config file.
+	Theme    string   `toml:"theme"`
+	Playback Playback `toml:"playback"`
+	Spectrum Spectrum `toml:"spectrum"`
+	Keys     Keys     `toml:"keys,omitempty"`
+}
+
+type Playback struct {
+	SeekStep      time.Duration `toml:"seek_step"`
+	LargeSeekStep time.Duration `toml:"large_seek_step"`
+	VolumeStep    int           `toml:"volume_step"`
+	MaxVolume     int           `toml:"max_volume"`
+	SampleRate    int           `toml:"sample_rate"`
+	Buffer        time.Duration `toml:"buffer"`
+}
+
+type Spectrum struct {
+	FFTSize int     `toml:"fft_size"`
+	FloorDB float64 `toml:"floor_db"`
+}
+
+// Keys remaps bindings per scope by action name, e.g.
+//
+//	[keys.global]
+//	play_pause = ["space", "p"]
+//
+// Only the overridden actions are listed; their names and conflicts are
+// checked by the help package.
+type Keys struct {
+	Global map[string]KeyList `toml:"global,omitempty"`
+	Tracks map[string]KeyList `toml:"tracks,omitempty"`
+	Queue  map[string]KeyList `toml:"queue,omitempty"`
+}
+
+// KeyList is written either as one key or as an array of keys.
+type KeyList []string
+
+func (k *KeyList) UnmarshalTOML(value any) error {
+	switch v := value.(type) {
+	case string:
+		*k = KeyList{v}
+	case []any:
+		keys := make(KeyList, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("keys must be strings, got %v", item)
+			}
+			keys[i] = s
+		}
+		*k = keys
+	default:
+		return fmt.Errorf("want a key or an array of keys, got %v", value)
+	}
+	return nil
+}
+
+// DefaultPath is config.toml under $XDG_CONFIG_HOME, which defaults to
+// ~/.config.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(dir) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "tmp", "config.toml"), nil
+}
+
+// Load overlays the file at path onto defaults and validates the result. A
+// missing file is only an error when required is set, i.e. when the user
+// named it explicitly.
+func Load(path string, defaults Config, required bool) (Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return defaults, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := defaults
+	meta, err := toml.Decode(string(data), &cfg)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			if parseErr.LastKey != "" {
+				return Config{}, fmt.Errorf("%s:%d: %s: %s", path, parseErr.Position.Line, parseErr.LastKey, parseErr.Message)
+			}
+			return Config{}, fmt.Errorf("%s:%d: %s", path, parseErr.Position.Line, parseErr.Message)
+		}
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		// An unknown table makes everything in it unknown too; name only
+		// the table.
+		var keys []string
+		for _, key := range undecoded {
+			name := key.String()
+			if len(keys) > 0 && strings.HasPrefix(name, keys[len(keys)-1]+".") {
+				continue
+			}
+			keys = append(keys, name)
+		}
+		return Config{}, fmt.Errorf("%s: unknown setting %s", path, strings.Join(keys, ", "))
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if strings.ContainsRune(cfg.Theme, filepath.Separator) && !filepath.IsAbs(cfg.Theme) {
+		cfg.Theme = filepath.Join(filepath.Dir(path), cfg.Theme)
+	}
+	return cfg, nil
+}
+
+// Validate reports the first setting that is out of range, naming it the way
+// it is written in the file.
+func (c Config) Validate() error {
+	p, s := c.Playback, c.Spectrum
+	switch {
+	case c.Theme == "":
+		return errors.New("theme: must name a built-in theme or a theme file")
+	case p.SeekStep <= 0:
+		return fmt.Errorf("playback.seek_step: must be positive, got %s", p.SeekStep)
+	case p.LargeSeekStep <= 0:
+		return fmt.Errorf("playback.large_seek_step: must be positive, got %s", p.LargeSeekStep)
+	case p.MaxVolume < 100 || p.MaxVolume > 400:
+		return fmt.Errorf("playback.max_volume: must be between 100 and 400 percent, got %d", p.MaxVolume)
+	case p.VolumeStep < 1 || p.VolumeStep > p.MaxVolume:
+		return fmt.Errorf("playback.volume_step: must be between 1 and max_volume (%d), got %d", p.MaxVolume, p.VolumeStep)
+	case p.SampleRate < 8000 || p.SampleRate > 192000:
+		return fmt.Errorf("playback.sample_rate: must be between 8000 and 192000 Hz, got %d", p.SampleRate)
+	case p.Buffer < 10*time.Millisecond || p.Buffer > time.Second:
+		return fmt.Errorf("playback.buffer: must be between 10ms and 1s, got %s", p.Buffer)
+	case s.FFTSize < 256 || s.FFTSize > 16384 || bits.OnesCount(uint(s.FFTSize)) != 1:
+		return fmt.Errorf("spectrum.fft_size: must be a power of two between 256 and 16384, got %d", s.FFTSize)
+	case s.FloorDB < -160 || s.FloorDB > -20:
+		return fmt.Errorf("spectrum.floor_db: must be between -160 and -20, got %g", s.FloorDB)
+	}
+	return nil
+}
+
+// Write prints c as TOML in the layout Load reads.
+func Write(w io.Writer, c Config) error {
+	enc := toml.NewEncoder(w)
+	enc.Indent = ""
+	return enc.Encode(c)
+}
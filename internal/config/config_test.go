@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var defaults = Config{
+	Theme: "dark",
+	Playback: Playback{
+		SeekStep:      5 * time.Second,
+		LargeSeekStep: time.Minute,
+		VolumeStep:    10,
+		MaxVolume:     150,
+		SampleRate:    48000,
+		Buffer:        100 * time.Millisecond,
+	},
+	Spectrum: Spectrum{FFTSize: 1024, FloorDB: -72},
+}
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadOverlaysFileOnDefaults(t *testing.T) {
+	path := writeConfig(t, `
+[playback]
+seek_step = "10s"
+max_volume = 200
+sample_rate = 44100
+
+[spectrum]
+fft_size = 2048
+`)
+	cfg, err := Load(path, defaults, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := defaults
+	want.Playback.SeekStep = 10 * time.Second
+	want.Playback.MaxVolume = 200
+	want.Playback.SampleRate = 44100
+	want.Spectrum.FFTSize = 2048
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("Load() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if cfg, err := Load(path, defaults, false); err != nil || !reflect.DeepEqual(cfg, defaults) {
+		t.Fatalf("Load() = %+v, %v; want the defaults for an absent default file", cfg, err)
+	}
+	if _, err := Load(path, defaults, true); err == nil {
+		t.Fatal("Load() should fail for an explicitly named file that is missing")
+	}
+}
+
+func TestLoadReportsInvalidSettingsClearly(t *testing.T) {
+	for _, tc := range []struct {
+		data, want string
+	}{
+		{"[playback]\nvolume_step = 0\n", "playback.volume_step: must be between 1 and max_volume (150), got 0"},
+		{"[spectrum]\nfft_size = 1000\n", "spectrum.fft_size: must be a power of two"},
+		{"[playback]\nbuffer = \"5s\"\n", "playback.buffer: must be between 10ms and 1s, got 5s"},
+		{"[playback]\nseek_step = \"soon\"\n", "config.toml:2: playback.seek_step: invalid duration"},
+		{"[playback]\nsample_rat = 44100\n", "unknown setting playback.sample_rat"},
+		{"[playback\n", "to end table name"},
+		{"[keys.global]\nplay_pause = 1\n", "want a key or an array of keys"},
+		{"theme = \"\"\n", "theme: must name a built-in theme or a theme file"},
+	} {
+		_, err := Load(writeConfig(t, tc.data), defaults, true)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load(%q) error = %v, want it to mention %q", tc.data, err, tc.want)
+		}
+	}
+}
+
+func TestLoadNamesUnknownTableOnce(t *testing.T) {
+	_, err := Load(writeConfig(t, "[keys.player]\nup = \"u\"\ndown = \"d\"\n"), defaults, true)
+	if err == nil || !strings.HasSuffix(err.Error(), ": unknown setting keys.player") {
+		t.Fatalf("Load() error = %v, want only the unknown table named", err)
+	}
+}
+
+func TestLoadReadsKeyOverrides(t *testing.T) {
+	path := writeConfig(t, `
+[keys.global]
+play_pause = "space"
+quit = ["q", "ctrl+c"]
+
+[keys.queue]
+dequeue_selected = ["x"]
+`)
+	cfg, err := Load(path, defaults, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Keys{
+		Global: map[string]KeyList{"play_pause": {"space"}, "quit": {"q", "ctrl+c"}},
+		Queue:  map[string]KeyList{"dequeue_selected": {"x"}},
+	}
+	if !reflect.DeepEqual(cfg.Keys, want) {
+		t.Fatalf("keys = %+v, want %+v", cfg.Keys, want)
+	}
+}
+
+func TestWriteRoundTrips(t *testing.T) {
+	var b strings.Builder
+	if err := Write(&b, defaults); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `seek_step = "5s"`) || strings.Contains(b.String(), "[keys") {
+		t.Fatalf("Write() = %q, want readable durations", b.String())
+	}
+	cfg, err := Load(writeConfig(t, b.String()), Config{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, defaults) {
+		t.Fatalf("round trip = %+v, want %+v", cfg, defaults)
+	}
+}
+
+func TestDefaultPathFollowsXDGConfigHome(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	path, err := DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("/xdg/config", "tmp", "config.toml"); path != want {
+		t.Fatalf("DefaultPath() = %q, want %q", path, want)
+	}
+}
+
+func TestLoadResolvesThemeFileNextToConfig(t *testing.T) {
+	path := writeConfig(t, "theme = \"themes/mine.toml\"\n")
+	cfg, err := Load(path, defaults, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(filepath.Dir(path), "themes", "mine.toml"); cfg.Theme != want {
+		t.Fatalf("Theme = %q, want %q", cfg.Theme, want)
+	}
+
+	cfg, err = Load(writeConfig(t, "theme = \"light\"\n"), defaults, true)
+	if err != nil || cfg.Theme != "light" {
+		t.Fatalf("Load() = %q, %v; want the built-in name unchanged", cfg.Theme, err)
+	}
+}
@@ -8,6 +8,10 @@ type Control struct {
 	*beep.Ctrl
 	Source beep.StreamSeekCloser
 	Loop   bool
+	// Speed is the playback rate, from 0.5 to 2. By default pitch follows
+	// speed; PreservePitch time-stretches instead.
+	Speed         float64
+	PreservePitch bool
 }
 
 func New(source beep.StreamSeekCloser) Control {
@@ -15,5 +19,14 @@ func New(source beep.StreamSeekCloser) Control {
 		Ctrl:   &beep.Ctrl{Streamer: source, Paused: false},
 		Source: source,
 		Loop:   false,
+		Speed:  1,
 	}
 }
+
+// Rate returns Speed, treating an unset speed as normal.
+func (c Control) Rate() float64 {
+	if c.Speed <= 0 {
+		return 1
+	}
+	return c.Speed
+}
@@ -0,0 +1,138 @@
+package decode
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gopxl/beep/v2"
+)
+
+// sniffLength is how many leading bytes are handed to Format.Sniff.
+const sniffLength = 64
+
+// ErrUnsupported is returned for files no registered format recognizes.
+var ErrUnsupported = errors.New("unsupported audio format")
+
+// DecodeFunc decodes r, which the returned streamer owns on success.
+type DecodeFunc func(r io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error)
+
+type Format struct {
+	Name string
+	// Extensions are matched case-insensitively and include the dot.
+	Extensions []string
+	// Sniff reports whether the leading bytes of a file belong to the
+	// format. It may be nil for formats only known by extension.
+	Sniff func(header []byte) bool
+	// Priority orders the sniffers, lowest first. Formats with a magic
+	// number leave it zero; looser checks such as a bare MP3 frame sync
+	// set it higher so they cannot shadow a real signature.
+	Priority int
+	Decode   DecodeFunc
+}
+
+var formats []Format
+
+// Register adds a format to the registry. Sniffers are tried by Priority,
+// then in registration order, and the first match wins.
+func Register(format Format) {
+	exts := make([]string, len(format.Extensions))
+	for i, ext := range format.Extensions {
+		exts[i] = strings.ToLower(ext)
+	}
+	format.Extensions = exts
+	formats = append(formats, format)
+	slices.SortStableFunc(formats, func(a, b Format) int {
+		return a.Priority - b.Priority
+	})
+}
+
+// Formats returns the registered formats in sniffing order.
+func Formats() []Format {
+	return append([]Format(nil), formats...)
+}
+
+// Extensions lists every registered extension, lower-cased.
+func Extensions() []string {
+	var exts []string
+	for _, format := range formats {
+		exts = append(exts, format.Extensions...)
+	}
+	return exts
+}
+
+// Supported reports whether path has a registered extension.
+func Supported(path string) bool {
+	_, ok := ByExtension(path)
+	return ok
+}
+
+// ByExtension finds the format registered for path's extension.
+func ByExtension(path string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, format := range formats {
+		for _, candidate := range format.Extensions {
+			if ext == candidate {
+				return format, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// ByHeader finds the first format whose sniffer accepts header.
+func ByHeader(header []byte) (Format, bool) {
+	for _, format := range formats {
+		if format.Sniff != nil && format.Sniff(header) {
+			return format, true
+		}
+	}
+	return Format{}, false
+}
+
+// Detect picks the format for r from its leading bytes, falling back to the
+// extension of path when no signature matches. r is rewound before returning.
+func Detect(path string, r io.ReadSeeker) (Format, error) {
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Format{}, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Format{}, err
+	}
+
+	if format, ok := ByHeader(header[:n]); ok {
+		return format, nil
+	}
+	if format, ok := ByExtension(path); ok {
+		return format, nil
+	}
+	return Format{}, fmt.Errorf("%w: %s", ErrUnsupported, filepath.Ext(path))
+}
+
+// File opens and decodes the audio file at path. On success the returned
+// streamer owns the file and closes it.
+func File(path string) (beep.StreamSeekCloser, beep.Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	format, err := Detect(path, f)
+	if err != nil {
+		_ = f.Close()
+		return nil, beep.Format{}, err
+	}
+
+	streamer, audioFormat, err := format.Decode(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, beep.Format{}, fmt.Errorf("%s: %w", format.Name, err)
+	}
+	return streamer, audioFormat, nil
+}
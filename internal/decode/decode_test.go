@@ -0,0 +1,214 @@
+package decode
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// writeFLACFixture encodes a stereo ramp with a short final frame. When
+// knownLength is false the stream info omits the total sample count, as
+// streamed encoders do.
+func writeFLACFixture(t *testing.T, path string, bitsPerSample uint8, samples, blockSize int, knownLength bool) {
+	t.Helper()
+
+	info := &meta.StreamInfo{
+		BlockSizeMin:  uint16(blockSize),
+		BlockSizeMax:  uint16(blockSize),
+		SampleRate:    8000,
+		NChannels:     2,
+		BitsPerSample: bitsPerSample,
+	}
+	var buf bytes.Buffer
+	enc, err := flac.NewEncoder(&buf, info)
+	if err != nil {
+		t.Fatalf("create flac encoder: %v", err)
+	}
+	for start := 0; start < samples; start += blockSize {
+		n := min(blockSize, samples-start)
+		left := make([]int32, n)
+		right := make([]int32, n)
+		for i := range left {
+			left[i] = int32(start + i)
+			right[i] = -int32(start + i)
+		}
+		f := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(n),
+				SampleRate:        info.SampleRate,
+				Channels:          frame.ChannelsLR,
+				BitsPerSample:     bitsPerSample,
+			},
+			Subframes: []*frame.Subframe{
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: left, NSamples: n},
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: right, NSamples: n},
+			},
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatalf("write flac frame: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("close flac encoder: %v", err)
+	}
+
+	data := buf.Bytes()
+	if knownLength {
+		// The encoder only back-fills stream info on seekable writers, so
+		// patch the 36-bit sample count in place.
+		packed := uint64(samples)
+		data[21] = data[21]&0xf0 | byte(packed>>32)&0x0f
+		data[22] = byte(packed >> 24)
+		data[23] = byte(packed >> 16)
+		data[24] = byte(packed >> 8)
+		data[25] = byte(packed)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write flac fixture %s: %v", path, err)
+	}
+}
+
+func TestDecodeFLACReports24BitLengthAndSeeksIntoFinalFrame(t *testing.T) {
+	for _, knownLength := range []bool{true, false} {
+		path := filepath.Join(t.TempDir(), "ramp.flac")
+		writeFLACFixture(t, path, 24, 1000, 256, knownLength)
+
+		streamer, format, err := File(path)
+		if err != nil {
+			t.Fatalf("decode flac (known length %v): %v", knownLength, err)
+		}
+
+		if format.Precision != 3 {
+			t.Fatalf("precision = %d, want 3 bytes for 24-bit source", format.Precision)
+		}
+		if got := streamer.Len(); got != 1000 {
+			t.Fatalf("len = %d, want 1000 (known length %v)", got, knownLength)
+		}
+
+		if err := streamer.Seek(900); err != nil {
+			t.Fatalf("seek into final frame: %v", err)
+		}
+		samples := make([][2]float64, 200)
+		n, ok := streamer.Stream(samples)
+		if !ok || n != 100 {
+			t.Fatalf("stream after seek = (%d, %v), want (100, true)", n, ok)
+		}
+		if want := 900.0 / (1 << 23); samples[0][0] != want || samples[0][1] != -want {
+			t.Fatalf("first sample after seek = %v, want [%v %v]", samples[0], want, -want)
+		}
+		if got := streamer.Position(); got != 1000 {
+			t.Fatalf("position = %d, want 1000 at end of stream", got)
+		}
+		if n, ok := streamer.Stream(samples); ok || n != 0 {
+			t.Fatalf("stream at end = (%d, %v), want (0, false)", n, ok)
+		}
+		if err := streamer.Close(); err != nil {
+			t.Fatalf("close flac: %v", err)
+		}
+	}
+}
+
+func TestDetectPrefersHeaderOverExtension(t *testing.T) {
+	cases := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"song.wav", []byte("ID3\x04\x00"), "mp3"},
+		{"song.mp3", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), "wav"},
+		{"song.ogg", []byte("fLaC\x00\x00\x00\x22"), "flac"},
+		{"song.flac", append([]byte("OggS\x00\x02"), []byte("........................\x01vorbis")...), "ogg/vorbis"},
+		{"song.wav", []byte{0xff, 0xfb, 0x90, 0x64}, "mp3"},
+		{"song.flac", []byte("unknown"), "flac"},
+	}
+
+	for _, tc := range cases {
+		format, err := Detect(tc.name, bytes.NewReader(tc.header))
+		if err != nil {
+			t.Fatalf("detect %s: %v", tc.name, err)
+		}
+		if format.Name != tc.want {
+			t.Fatalf("detect %s with header %q = %s, want %s", tc.name, tc.header, format.Name, tc.want)
+		}
+	}
+}
+
+func TestRegisterCopiesExtensionsAndOrdersSniffersByPriority(t *testing.T) {
+	saved := formats
+	t.Cleanup(func() { formats = saved })
+	formats = nil
+
+	exts := []string{".LOOSE"}
+	Register(Format{Name: "loose", Extensions: exts, Priority: 1, Sniff: func([]byte) bool { return true }})
+	Register(Format{Name: "magic", Extensions: []string{".magic"}, Sniff: func(h []byte) bool { return bytes.HasPrefix(h, []byte("MAGC")) }})
+	if exts[0] != ".LOOSE" {
+		t.Fatalf("Register changed the caller's extensions to %q", exts)
+	}
+	if format, ok := ByExtension("x.loose"); !ok || format.Name != "loose" {
+		t.Fatalf("ByExtension(x.loose) = %s, %v; want loose", format.Name, ok)
+	}
+	if format, _ := ByHeader([]byte("MAGC")); format.Name != "magic" {
+		t.Fatalf("ByHeader(MAGC) = %s, want the magic number ahead of the registered-earlier loose sniffer", format.Name)
+	}
+	if format, _ := ByHeader([]byte("????")); format.Name != "loose" {
+		t.Fatalf("ByHeader(????) = %s, want the loose sniffer as a fallback", format.Name)
+	}
+}
+
+func TestDetectRejectsUnknownFiles(t *testing.T) {
+	_, err := Detect("notes.txt", bytes.NewReader([]byte("hello")))
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("detect error = %v, want ErrUnsupported", err)
+	}
+	if Supported("notes.txt") {
+		t.Fatal("Supported(notes.txt) = true, want false")
+	}
+	if !Supported("LOUD.FLAC") {
+		t.Fatal("Supported(LOUD.FLAC) = false, want case-insensitive match")
+	}
+}
+
+func TestFileNamesFormatInDecodeErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.flac")
+	if err := os.WriteFile(path, []byte("fLaC\x00\x00"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := File(path)
+	if err == nil || err.Error() != "flac: unexpected EOF" {
+		t.Fatalf("decode error = %v, want flac: unexpected EOF", err)
+	}
+
+	path = filepath.Join(t.TempDir(), "broken.mid")
+	if err := os.WriteFile(path, []byte("MThd\x00\x00\x00\x06\x00\x09\x00\x01\x00\x60"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = File(path)
+	if err == nil || err.Error() != "midi: unsupported format 9" {
+		t.Fatalf("decode error = %v, want midi: unsupported format 9", err)
+	}
+}
+
+func TestFileRendersBundledMIDI(t *testing.T) {
+	streamer, format, err := File("../../sounds/wav/flourish.mid")
+	if err != nil {
+		t.Fatalf("decode midi: %v", err)
+	}
+	defer streamer.Close()
+
+	if format.NumChannels != 2 || format.SampleRate != midiSampleRate {
+		t.Fatalf("format = %+v, want stereo at %d Hz", format, midiSampleRate)
+	}
+	if streamer.Len() <= 0 {
+		t.Fatalf("len = %d, want positive length", streamer.Len())
+	}
+	if err := streamer.Seek(streamer.Len() / 2); err != nil {
+		t.Fatalf("seek midi: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package decode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gopxl/beep/v2"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+)
+
+// flacDecoder streams FLAC audio while tracking its own sample position, so
+// seeking and Len keep working for streams that omit the total sample count
+// and for the short final frame of fixed block size streams.
+type flacDecoder struct {
+	r          io.ReadSeekCloser
+	stream     *flac.Stream
+	frame      *frame.Frame
+	posInFrame int
+	position   int
+	length     int
+	scale      float64
+	err        error
+}
+
+func init() {
+	Register(Format{
+		Name:       "flac",
+		Extensions: []string{".flac"},
+		Sniff: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("fLaC"))
+		},
+		Decode: decodeFLAC,
+	})
+}
+
+func decodeFLAC(r io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	stream, err := flac.NewSeek(r)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	info := stream.Info
+	if info.NChannels == 0 || info.BitsPerSample == 0 {
+		return nil, beep.Format{}, errors.New("invalid stream info")
+	}
+
+	d := &flacDecoder{
+		r:      r,
+		stream: stream,
+		length: int(info.NSamples),
+		scale:  1 / float64(int64(1)<<(info.BitsPerSample-1)),
+	}
+	if d.length == 0 {
+		if d.length, err = countFLACSamples(stream); err != nil {
+			return nil, beep.Format{}, err
+		}
+		if _, err := stream.Seek(0); err != nil {
+			return nil, beep.Format{}, err
+		}
+	}
+
+	format := beep.Format{
+		SampleRate:  beep.SampleRate(info.SampleRate),
+		NumChannels: min(int(info.NChannels), 2),
+		Precision:   (int(info.BitsPerSample) + 7) / 8,
+	}
+	return d, format, nil
+}
+
+func countFLACSamples(stream *flac.Stream) (int, error) {
+	total := 0
+	for {
+		f, err := stream.ParseNext()
+		if errors.Is(err, io.EOF) {
+			return total, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		total += int(f.BlockSize)
+	}
+}
+
+func (d *flacDecoder) Stream(samples [][2]float64) (int, bool) {
+	if d.err != nil {
+		return 0, false
+	}
+
+	n := 0
+	for n < len(samples) {
+		if d.frame == nil || d.posInFrame >= int(d.frame.BlockSize) {
+			if d.position >= d.length {
+				break
+			}
+			f, err := d.stream.ParseNext()
+			if err != nil {
+				d.frame = nil
+				if !errors.Is(err, io.EOF) {
+					d.err = err
+				}
+				break
+			}
+			d.frame = f
+			d.posInFrame = 0
+			continue
+		}
+
+		count := min(int(d.frame.BlockSize)-d.posInFrame, len(samples)-n, d.length-d.position)
+		left := d.frame.Subframes[0].Samples[d.posInFrame:]
+		right := left
+		if len(d.frame.Subframes) > 1 {
+			right = d.frame.Subframes[1].Samples[d.posInFrame:]
+		}
+		for i := 0; i < count; i++ {
+			samples[n+i][0] = float64(left[i]) * d.scale
+			samples[n+i][1] = float64(right[i]) * d.scale
+		}
+		d.posInFrame += count
+		d.position += count
+		n += count
+	}
+	return n, n > 0
+}
+
+func (d *flacDecoder) Err() error {
+	return d.err
+}
+
+func (d *flacDecoder) Len() int {
+	return d.length
+}
+
+func (d *flacDecoder) Position() int {
+	return d.position
+}
+
+func (d *flacDecoder) Seek(p int) error {
+	if p < 0 || p > d.length {
+		return fmt.Errorf("flac: seek position %d out of range [0, %d]", p, d.length)
+	}
+	d.err = nil
+	if p == d.length {
+		d.frame = nil
+		d.position = p
+		return nil
+	}
+
+	// The library reports the wrong start sample for the short final frame of
+	// fixed block size streams, so land on an earlier frame and walk forward
+	// using block sizes instead of trusting frame numbers.
+	target := max(0, p-int(d.stream.Info.BlockSizeMax)-1)
+	start, err := d.stream.Seek(uint64(target))
+	if err != nil {
+		return err
+	}
+	frameStart := int(start)
+	for {
+		f, err := d.stream.ParseNext()
+		if err != nil {
+			return err
+		}
+		if frameStart+int(f.BlockSize) > p {
+			d.frame = f
+			d.posInFrame = p - frameStart
+			d.position = p
+			return nil
+		}
+		frameStart += int(f.BlockSize)
+	}
+}
+
+func (d *flacDecoder) Close() error {
+	return d.r.Close()
+}
@@ -0,0 +1,37 @@
+package decode
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gopxl/beep/v2"
+	"github.com/kjloveless/tmp/internal/midi"
+)
+
+const midiSampleRate beep.SampleRate = 44100
+
+func init() {
+	Register(Format{
+		Name:       "midi",
+		Extensions: []string{".mid", ".midi"},
+		Sniff: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("MThd"))
+		},
+		Decode: decodeMIDI,
+	})
+}
+
+// decodeMIDI parses the whole file up front and renders it with the built-in
+// synthesizer, so r is closed before returning.
+func decodeMIDI(r io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	file, err := midi.Parse(r)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	if err := r.Close(); err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	format := beep.Format{SampleRate: midiSampleRate, NumChannels: 2, Precision: 2}
+	return midi.NewStreamer(file, midiSampleRate), format, nil
+}
@@ -0,0 +1,35 @@
+package decode
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gopxl/beep/v2"
+	"github.com/gopxl/beep/v2/mp3"
+)
+
+func init() {
+	Register(Format{
+		Name:       "mp3",
+		Extensions: []string{".mp3"},
+		Sniff:      sniffMP3,
+		// A frame sync is only eleven set bits, so it goes after the
+		// formats with magic numbers.
+		Priority: 1,
+		Decode: func(r io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			return mp3.Decode(r)
+		},
+	})
+}
+
+func sniffMP3(header []byte) bool {
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		return true
+	}
+	// MPEG audio frame sync: eleven set bits, a valid version and layer III.
+	return len(header) >= 2 &&
+		header[0] == 0xff &&
+		header[1]&0xe0 == 0xe0 &&
+		header[1]&0x18 != 0x08 &&
+		header[1]&0x06 == 0x02
+}
@@ -0,0 +1,27 @@
+package decode
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gopxl/beep/v2"
+	"github.com/gopxl/beep/v2/vorbis"
+)
+
+func init() {
+	Register(Format{
+		Name:       "ogg/vorbis",
+		Extensions: []string{".ogg"},
+		Sniff:      sniffVorbis,
+		Decode: func(r io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			return vorbis.Decode(r)
+		},
+	})
+}
+
+func sniffVorbis(header []byte) bool {
+	// The first Ogg page carries the Vorbis identification header right after
+	// a single-segment page header.
+	return bytes.HasPrefix(header, []byte("OggS")) &&
+		bytes.Contains(header, []byte("\x01vorbis"))
+}
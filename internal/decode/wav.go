@@ -0,0 +1,26 @@
+package decode
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gopxl/beep/v2"
+	"github.com/gopxl/beep/v2/wav"
+)
+
+func init() {
+	Register(Format{
+		Name:       "wav",
+		Extensions: []string{".wav"},
+		Sniff:      sniffWAV,
+		Decode: func(r io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			return wav.Decode(r)
+		},
+	})
+}
+
+func sniffWAV(header []byte) bool {
+	return len(header) >= 12 &&
+		bytes.Equal(header[:4], []byte("RIFF")) &&
+		bytes.Equal(header[8:12], []byte("WAVE"))
+}
@@ -0,0 +1,192 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"slices"
+
+	"github.com/gopxl/beep/v2"
+)
+
+const (
+	// MaxBandGain bounds each band in dB so a preset cannot overload the
+	// limiter.
+	MaxBandGain = 12.0
+	octaveQ     = math.Sqrt2
+)
+
+// Band is one peaking filter of the equalizer. Gain is in dB.
+type Band struct {
+	Frequency float64
+	Gain      float64
+	Q         float64
+}
+
+type Preset struct {
+	Name  string
+	Bands []Band
+}
+
+// Clone returns a copy whose bands can be edited without touching p.
+func (p Preset) Clone() Preset {
+	p.Bands = slices.Clone(p.Bands)
+	return p
+}
+
+// Flat reports whether every band is at 0 dB.
+func (p Preset) Flat() bool {
+	for _, b := range p.Bands {
+		if b.Gain != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// BandFrequencies are the octave-spaced centers of the built-in presets.
+var BandFrequencies = []float64{31, 62, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+func tenBand(name string, gains ...float64) Preset {
+	bands := make([]Band, len(BandFrequencies))
+	for i, freq := range BandFrequencies {
+		bands[i] = Band{Frequency: freq, Gain: gains[i], Q: octaveQ}
+	}
+	return Preset{Name: name, Bands: bands}
+}
+
+// Presets are cycled in order; the first one is flat.
+var Presets = []Preset{
+	tenBand("flat", 0, 0, 0, 0, 0, 0, 0, 0, 0, 0),
+	tenBand("bass boost", 6, 5, 4, 2, 0, 0, 0, 0, 0, 0),
+	tenBand("vocal", -3, -2, -1, 0, 2, 4, 4, 3, 1, 0),
+	// Small speakers roll off below a few hundred hertz and sound harsh
+	// around 3 kHz.
+	tenBand("laptop speakers", 0, 2, 5, 5, 3, 0, -1, -2, 0, 2),
+}
+
+// biquad is an RBJ peaking filter in direct form I with stereo state.
+type biquad struct {
+	frequency          float64
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     [2]float64
+}
+
+func peaking(band Band, sampleRate float64) biquad {
+	a := math.Pow(10, band.Gain/40)
+	w0 := 2 * math.Pi * band.Frequency / sampleRate
+	alpha := math.Sin(w0) / (2 * band.Q)
+	a0 := 1 + alpha/a
+	return biquad{
+		frequency: band.Frequency,
+		b0:        (1 + alpha*a) / a0,
+		b1:        -2 * math.Cos(w0) / a0,
+		b2:        (1 - alpha*a) / a0,
+		a1:        -2 * math.Cos(w0) / a0,
+		a2:        (1 - alpha/a) / a0,
+	}
+}
+
+func (f *biquad) process(c int, x float64) float64 {
+	y := f.b0*x + f.b1*f.x1[c] + f.b2*f.x2[c] - f.a1*f.y1[c] - f.a2*f.y2[c]
+	f.x2[c], f.x1[c] = f.x1[c], x
+	f.y2[c], f.y1[c] = f.y1[c], y
+	return y
+}
+
+// response is the filter's magnitude in dB at freq.
+func (f biquad) response(freq, sampleRate float64) float64 {
+	z := cmplx.Exp(complex(0, -2*math.Pi*freq/sampleRate))
+	num := complex(f.b0, 0) + complex(f.b1, 0)*z + complex(f.b2, 0)*z*z
+	den := 1 + complex(f.a1, 0)*z + complex(f.a2, 0)*z*z
+	return 20 * math.Log10(cmplx.Abs(num/den))
+}
+
+// Equalizer runs a chain of peaking filters over a stream. An equalizer
+// that has only ever been flat bypasses the filters entirely.
+type Equalizer struct {
+	Streamer beep.Streamer
+
+	sampleRate float64
+	preset     Preset
+	filters    []biquad
+	// x1 and x2 are the last two input samples. Filters that engage
+	// mid-stream start from them, as if they had been passing the signal
+	// through all along.
+	x1, x2 [2]float64
+}
+
+func NewEqualizer(streamer beep.Streamer, sampleRate beep.SampleRate, preset Preset) *Equalizer {
+	e := &Equalizer{Streamer: streamer, sampleRate: float64(sampleRate)}
+	e.SetPreset(preset)
+	return e
+}
+
+// SetPreset retunes the filters. A band that keeps its frequency keeps its
+// history, so switching presets during playback does not click. Callers
+// must hold the speaker lock while the equalizer is playing.
+func (e *Equalizer) SetPreset(preset Preset) {
+	e.preset = preset.Clone()
+	// Filters already running are retuned to 0 dB rather than dropped:
+	// cutting straight to the dry signal would be a step in the output.
+	if e.sampleRate <= 0 || preset.Flat() && len(e.filters) == 0 {
+		return
+	}
+	previous := e.filters
+	filters := make([]biquad, 0, len(preset.Bands))
+	for _, band := range preset.Bands {
+		// Filters at or above Nyquist cannot be realised. Bands at 0 dB
+		// stay in the chain so their history is ready when they change.
+		if band.Frequency <= 0 || band.Frequency >= e.sampleRate/2 {
+			continue
+		}
+		f := peaking(band, e.sampleRate)
+		if i := len(filters); i < len(previous) && previous[i].frequency == f.frequency {
+			f.x1, f.x2, f.y1, f.y2 = previous[i].x1, previous[i].x2, previous[i].y1, previous[i].y2
+		} else {
+			f.x1, f.x2, f.y1, f.y2 = e.x1, e.x2, e.x1, e.x2
+		}
+		filters = append(filters, f)
+	}
+	e.filters = filters
+}
+
+func (e *Equalizer) Stream(samples [][2]float64) (int, bool) {
+	n, ok := e.Streamer.Stream(samples)
+	switch {
+	case n >= 2:
+		e.x2, e.x1 = samples[n-2], samples[n-1]
+	case n == 1:
+		e.x2, e.x1 = e.x1, samples[0]
+	}
+	if len(e.filters) == 0 {
+		return n, ok
+	}
+	for i := range samples[:n] {
+		for c := range 2 {
+			x := samples[i][c]
+			for f := range e.filters {
+				x = e.filters[f].process(c, x)
+			}
+			samples[i][c] = x
+		}
+	}
+	return n, ok
+}
+
+func (e *Equalizer) Err() error {
+	return e.Streamer.Err()
+}
+
+// Response returns the combined gain of a preset in dB at freq, for drawing
+// its curve.
+func Response(preset Preset, sampleRate beep.SampleRate, freq float64) float64 {
+	sr := float64(sampleRate)
+	var db float64
+	for _, band := range preset.Bands {
+		if band.Gain == 0 || band.Frequency >= sr/2 || freq >= sr/2 {
+			continue
+		}
+		db += peaking(band, sr).response(freq, sr)
+	}
+	return db
+}
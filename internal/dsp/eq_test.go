@@ -0,0 +1,85 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep/v2"
+)
+
+func rms(samples [][2]float64) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s[0] * s[0]
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func TestFlatEqualizerPassesSamplesThrough(t *testing.T) {
+	const sampleRate = beep.SampleRate(44100)
+	n := sampleRate.N(50 * time.Millisecond)
+	want := drain(sine(sampleRate, 440, 0.5, n))
+	got := drain(NewEqualizer(sine(sampleRate, 440, 0.5, n), sampleRate, Presets[0]))
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEqualizerBoostMatchesResponse(t *testing.T) {
+	const sampleRate = beep.SampleRate(44100)
+	preset := Preset{Name: "test", Bands: []Band{{Frequency: 1000, Gain: 6, Q: octaveQ}}}
+	if got := Response(preset, sampleRate, 1000); math.Abs(got-6) > 1e-9 {
+		t.Fatalf("Response at center = %.3f dB, want 6", got)
+	}
+	if got := Response(preset, sampleRate, 50); math.Abs(got) > 0.1 {
+		t.Fatalf("Response far below the band = %.3f dB, want about 0", got)
+	}
+
+	n := sampleRate.N(time.Second)
+	dry := drain(sine(sampleRate, 1000, 0.1, n))
+	wet := drain(NewEqualizer(sine(sampleRate, 1000, 0.1, n), sampleRate, preset))
+	// Skip the filter's settling time.
+	settle := sampleRate.N(100 * time.Millisecond)
+	if got := 20 * math.Log10(rms(wet[settle:])/rms(dry[settle:])); math.Abs(got-6) > 0.05 {
+		t.Fatalf("measured gain = %.3f dB, want 6", got)
+	}
+}
+
+func TestEqualizerSkipsBandsAboveNyquist(t *testing.T) {
+	preset := Preset{Name: "test", Bands: []Band{
+		{Frequency: 1000, Gain: 3, Q: octaveQ},
+		{Frequency: 16000, Gain: 3, Q: octaveQ},
+	}}
+	eq := NewEqualizer(nil, 22050, preset)
+	if len(eq.filters) != 1 {
+		t.Fatalf("filters = %d, want the 16 kHz band skipped at 22.05 kHz", len(eq.filters))
+	}
+	if got := Response(preset, 22050, 16000); got != 0 {
+		t.Fatalf("Response above Nyquist = %v, want 0", got)
+	}
+}
+
+func TestEqualizerSwitchesPresetsWithoutAStep(t *testing.T) {
+	const sampleRate = beep.SampleRate(44100)
+	for _, from := range Presets {
+		for _, to := range Presets {
+			eq := NewEqualizer(sine(sampleRate, 100, 0.5, sampleRate.N(time.Second)), sampleRate, from)
+			buf := make([][2]float64, 4096)
+			eq.Stream(buf)
+			largest := 0.0
+			for i := 1; i < len(buf); i++ {
+				largest = max(largest, math.Abs(buf[i][0]-buf[i-1][0]))
+			}
+			last := buf[len(buf)-1]
+
+			eq.SetPreset(to)
+			eq.Stream(buf[:1])
+			if step := math.Abs(buf[0][0] - last[0]); step > largest {
+				t.Errorf("%s to %s: output jumped %.4f, more than the signal's largest step %.4f", from.Name, to.Name, step, largest)
+			}
+		}
+	}
+}
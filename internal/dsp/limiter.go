@@ -0,0 +1,180 @@
+package dsp
+
+import (
+	"math"
+	"time"
+
+	"github.com/gopxl/beep/v2"
+)
+
+const (
+	limiterCeilingDB = -0.3
+	limiterLookahead = 5 * time.Millisecond
+	limiterRelease   = 150 * time.Millisecond
+	limiterHold      = time.Second
+
+	reductionThreshold = 0.01 // dB
+)
+
+// Limiter is a look-ahead peak limiter. Samples are delayed by the
+// look-ahead so the gain can ramp down before a peak arrives; the gain is
+// the sliding minimum of the per-sample gain each peak needs, released
+// smoothly and then averaged over the look-ahead window. Every averaged
+// value covers the delayed sample, so the output never exceeds the ceiling.
+// Below the ceiling the signal passes through unchanged apart from the delay.
+// When the source runs dry the delay line is flushed before the limiter
+// reports itself drained.
+type Limiter struct {
+	Streamer beep.Streamer
+
+	ceiling     float64
+	lookahead   int
+	releaseCoef float64
+	holdLength  int
+
+	delay    [][2]float64
+	buffered int  // source samples still in the delay line
+	drained  bool // the source has run dry; the delay line is being flushed
+
+	// minGain and minAt are a ring-buffered monotonic deque of required
+	// gains and the sample they belong to, so the sliding minimum is the
+	// front entry. The window spans one more sample than the delay so that
+	// it always includes the sample leaving the delay line.
+	minGain  []float64
+	minAt    []int
+	minFront int
+	minLen   int
+	sample   int
+
+	window    []float64 // ring of released gains for the moving average
+	windowSum float64
+	pos       int
+	released  float64
+
+	reduction float64 // largest recent gain reduction in dB
+	hold      int
+}
+
+func NewLimiter(streamer beep.Streamer, sampleRate beep.SampleRate) *Limiter {
+	lookahead := max(sampleRate.N(limiterLookahead), 1)
+	l := &Limiter{
+		Streamer:    streamer,
+		ceiling:     math.Pow(10, limiterCeilingDB/20),
+		lookahead:   lookahead,
+		releaseCoef: 1 - math.Exp(-1/float64(sampleRate.N(limiterRelease))),
+		holdLength:  sampleRate.N(limiterHold),
+		delay:       make([][2]float64, lookahead),
+		minGain:     make([]float64, lookahead+1),
+		minAt:       make([]int, lookahead+1),
+		window:      make([]float64, lookahead),
+		windowSum:   float64(lookahead),
+		released:    1,
+	}
+	for i := range l.window {
+		l.window[i] = 1
+	}
+	return l
+}
+
+func (l *Limiter) Stream(samples [][2]float64) (int, bool) {
+	n := 0
+	if !l.drained {
+		var ok bool
+		n, ok = l.Streamer.Stream(samples)
+		l.drained = !ok || n < len(samples)
+	}
+	minGain := 1.0
+	for i := range samples[:n] {
+		var gain float64
+		samples[i], gain = l.process(samples[i])
+		minGain = min(minGain, gain)
+	}
+	l.buffered = min(l.buffered+n, l.lookahead)
+	if l.drained {
+		// Push silence through to release what is left in the delay line.
+		tail := min(len(samples)-n, l.buffered)
+		for i := n; i < n+tail; i++ {
+			var gain float64
+			samples[i], gain = l.process([2]float64{})
+			minGain = min(minGain, gain)
+		}
+		l.buffered -= tail
+		n += tail
+	}
+
+	l.hold -= n
+	if reduction := -20 * math.Log10(minGain); reduction > reductionThreshold {
+		if l.hold <= 0 || reduction > l.reduction {
+			l.reduction = reduction
+		}
+		l.hold = l.holdLength
+	}
+	return n, n > 0 || !l.drained
+}
+
+// process feeds one sample in and returns the delayed sample leaving the
+// delay line with the gain applied to it.
+func (l *Limiter) process(in [2]float64) ([2]float64, float64) {
+	peak := max(math.Abs(in[0]), math.Abs(in[1]))
+	required := 1.0
+	if peak > l.ceiling {
+		required = l.ceiling / peak
+	}
+	target := l.slidingMin(required)
+	if target < l.released {
+		l.released = target
+	} else {
+		l.released += (target - l.released) * l.releaseCoef
+	}
+
+	l.windowSum += l.released - l.window[l.pos]
+	l.window[l.pos] = l.released
+	gain := min(l.windowSum/float64(l.lookahead), 1)
+
+	out := l.delay[l.pos]
+	l.delay[l.pos] = in
+	l.pos = (l.pos + 1) % l.lookahead
+	if l.pos == 0 {
+		// Resum once per window so rounding errors cannot accumulate.
+		l.windowSum = 0
+		for _, g := range l.window {
+			l.windowSum += g
+		}
+	}
+	return [2]float64{out[0] * gain, out[1] * gain}, gain
+}
+
+// slidingMin adds the gain the next sample requires and returns the
+// smallest gain required over the window, in amortized constant time.
+func (l *Limiter) slidingMin(required float64) float64 {
+	size := len(l.minGain)
+	if l.minLen > 0 && l.minAt[l.minFront] <= l.sample-size {
+		l.minFront = (l.minFront + 1) % size
+		l.minLen--
+	}
+	for l.minLen > 0 {
+		back := (l.minFront + l.minLen - 1) % size
+		if l.minGain[back] < required {
+			break
+		}
+		l.minLen--
+	}
+	back := (l.minFront + l.minLen) % size
+	l.minGain[back], l.minAt[back] = required, l.sample
+	l.minLen++
+	l.sample++
+	return l.minGain[l.minFront]
+}
+
+func (l *Limiter) Err() error {
+	return l.Streamer.Err()
+}
+
+// Reduction returns the largest gain reduction in dB since the limiter last
+// engaged, or zero once it has been idle for a second.
+func (l *Limiter) Reduction() float64 {
+	if l.hold <= 0 {
+		return 0
+	}
+	return l.reduction
+}
@@ -0,0 +1,86 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep/v2"
+)
+
+func sine(sampleRate beep.SampleRate, freq, amplitude float64, n int) beep.Streamer {
+	position := 0
+	return beep.Take(n, beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			v := amplitude * math.Sin(2*math.Pi*freq*float64(position)/float64(sampleRate))
+			samples[i] = [2]float64{v, -v}
+			position++
+		}
+		return len(samples), true
+	}))
+}
+
+func drain(s beep.Streamer) [][2]float64 {
+	var out [][2]float64
+	buf := make([][2]float64, 512)
+	for {
+		n, ok := s.Stream(buf)
+		out = append(out, buf[:n]...)
+		if !ok {
+			return out
+		}
+	}
+}
+
+func TestLimiterKeepsPeaksBelowCeiling(t *testing.T) {
+	const sampleRate = beep.SampleRate(44100)
+	limiter := NewLimiter(sine(sampleRate, 440, 2, sampleRate.N(time.Second)), sampleRate)
+	ceiling := math.Pow(10, limiterCeilingDB/20)
+	for i, sample := range drain(limiter) {
+		if math.Abs(sample[0]) > ceiling+1e-12 || math.Abs(sample[1]) > ceiling+1e-12 {
+			t.Fatalf("sample %d = %v, exceeds ceiling %v", i, sample, ceiling)
+		}
+	}
+	// Amplitude 2 needs about 6.3 dB of reduction to reach -0.3 dBFS.
+	if got := limiter.Reduction(); got < 6 || got > 7 {
+		t.Fatalf("Reduction() = %.2f dB, want about 6.3", got)
+	}
+}
+
+func TestLimiterPassesQuietSignalUnchanged(t *testing.T) {
+	const sampleRate = beep.SampleRate(44100)
+	n := sampleRate.N(100 * time.Millisecond)
+	want := drain(sine(sampleRate, 440, 0.5, n))
+	limiter := NewLimiter(sine(sampleRate, 440, 0.5, n), sampleRate)
+	got := drain(limiter)
+
+	delay := limiter.lookahead
+	if len(got) != len(want)+delay {
+		t.Fatalf("streamed %d samples, want %d with the delay line flushed", len(got), len(want)+delay)
+	}
+	for i := delay; i < len(got); i++ {
+		if got[i] != want[i-delay] {
+			t.Fatalf("sample %d = %v, want %v delayed by %d", i, got[i], want[i-delay], delay)
+		}
+	}
+	if got := limiter.Reduction(); got != 0 {
+		t.Fatalf("Reduction() = %.2f dB, want 0", got)
+	}
+}
+
+func TestLimiterSlidingMinimumMatchesWindowScan(t *testing.T) {
+	limiter := NewLimiter(beep.Silence(0), beep.SampleRate(1000))
+	window := len(limiter.minGain)
+	var seen []float64
+	for i := range 500 {
+		required := 1 - math.Abs(math.Sin(float64(i)*0.37))*float64(i%7)/7
+		seen = append(seen, required)
+		want := 1.0
+		for _, r := range seen[max(0, len(seen)-window):] {
+			want = min(want, r)
+		}
+		if got := limiter.slidingMin(required); got != want {
+			t.Fatalf("sample %d: sliding minimum = %v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package dsp
+
+import (
+	"math"
+	"time"
+
+	"github.com/gopxl/beep/v2"
+)
+
+const (
+	stretchFrame     = 40 * time.Millisecond
+	stretchTolerance = 10 * time.Millisecond
+	// Only every few samples take part in the similarity search; speech and
+	// music are smooth enough at this spacing and the search stays cheap.
+	stretchSearchStride = 4
+	stretchReadSize     = 512
+)
+
+// TimeStretch changes tempo without changing pitch using WSOLA: Hann-windowed
+// frames are overlap-added at a fixed output hop while the input advances by
+// the hop times the speed. Each frame's start is nudged within a small
+// tolerance to the spot that best continues the previous frame, which avoids
+// the phasing of plain overlap-add.
+type TimeStretch struct {
+	Streamer beep.Streamer
+
+	speed     float64
+	frame     int
+	hop       int
+	tolerance int
+	window    []float64
+
+	input      [][2]float64 // input from absolute index inputStart on
+	inputStart int
+	read       [][2]float64 // scratch buffer for reading the source
+	drained    bool
+
+	frames   int // frames emitted so far
+	previous int // absolute input index of the previous frame
+	overlap  [][2]float64
+	hopOut   [][2]float64 // backs pending, reused for every frame
+	pending  [][2]float64 // finished output not yet streamed
+	err      error
+}
+
+func NewTimeStretch(streamer beep.Streamer, sampleRate beep.SampleRate, speed float64) *TimeStretch {
+	frame := max(sampleRate.N(stretchFrame)&^1, 4)
+	window := make([]float64, frame)
+	for i := range window {
+		// A periodic Hann window sums to one at half-frame hops.
+		window[i] = 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(frame)))
+	}
+	hop, tolerance := frame/2, sampleRate.N(stretchTolerance)
+	// The buffered input never spans more than one frame, the search
+	// window, one hop's advance and a partial read past them.
+	capacity := frame + 2*tolerance + int(math.Ceil(float64(hop)*speed)) + stretchReadSize
+	return &TimeStretch{
+		Streamer:  streamer,
+		speed:     speed,
+		frame:     frame,
+		hop:       hop,
+		tolerance: tolerance,
+		window:    window,
+		input:     make([][2]float64, 0, capacity),
+		read:      make([][2]float64, stretchReadSize),
+		previous:  -1,
+		overlap:   make([][2]float64, frame),
+		hopOut:    make([][2]float64, hop),
+	}
+}
+
+func (s *TimeStretch) Stream(samples [][2]float64) (int, bool) {
+	filled := 0
+	for filled < len(samples) {
+		if len(s.pending) == 0 && !s.nextFrame() {
+			break
+		}
+		n := copy(samples[filled:], s.pending)
+		s.pending = s.pending[n:]
+		filled += n
+	}
+	return filled, filled > 0
+}
+
+func (s *TimeStretch) Err() error {
+	return s.err
+}
+
+// fill reads input until index end is buffered or the source runs dry.
+func (s *TimeStretch) fill(end int) {
+	for !s.drained && s.inputStart+len(s.input) < end {
+		n, ok := s.Streamer.Stream(s.read)
+		s.input = append(s.input, s.read[:n]...)
+		if !ok {
+			s.drained = true
+			s.err = s.Streamer.Err()
+		}
+	}
+}
+
+func (s *TimeStretch) at(index int) [2]float64 {
+	i := index - s.inputStart
+	if i < 0 || i >= len(s.input) {
+		return [2]float64{}
+	}
+	return s.input[i]
+}
+
+// nextFrame overlap-adds one more frame and moves a hop of finished output
+// to pending. It returns false once the input is used up.
+func (s *TimeStretch) nextFrame() bool {
+	nominal := int(math.Round(float64(s.frames*s.hop) * s.speed))
+	s.fill(nominal + s.tolerance + s.frame)
+	end := s.inputStart + len(s.input)
+	if s.drained && nominal >= end {
+		if s.overlap == nil {
+			return false
+		}
+		// Flush the tail of the last frame.
+		s.pending = s.overlap[:s.hop]
+		s.overlap = nil
+		return true
+	}
+
+	start := nominal
+	if s.previous >= 0 {
+		start = s.bestMatch(nominal, s.previous+s.hop)
+	}
+	for i := range s.frame {
+		sample := s.at(start + i)
+		s.overlap[i][0] += sample[0] * s.window[i]
+		s.overlap[i][1] += sample[1] * s.window[i]
+	}
+	s.previous = start
+	s.frames++
+
+	s.pending = s.hopOut[:copy(s.hopOut, s.overlap[:s.hop])]
+	copy(s.overlap, s.overlap[s.hop:])
+	clear(s.overlap[s.frame-s.hop:])
+
+	// Input before both the next search window and the natural
+	// continuation is no longer needed.
+	next := int(math.Round(float64(s.frames*s.hop) * s.speed))
+	if drop := min(next-s.tolerance, start+s.hop) - s.inputStart; drop > 0 {
+		drop = min(drop, len(s.input))
+		s.input = append(s.input[:0], s.input[drop:]...)
+		s.inputStart += drop
+	}
+	return true
+}
+
+// bestMatch searches around nominal for the frame start whose first half is
+// most similar to the input that naturally follows the previous frame.
+func (s *TimeStretch) bestMatch(nominal, continuation int) int {
+	best, bestScore := nominal, math.Inf(-1)
+	for offset := -s.tolerance; offset <= s.tolerance; offset++ {
+		candidate := nominal + offset
+		if candidate < 0 {
+			continue
+		}
+		var score float64
+		for i := 0; i < s.hop; i += stretchSearchStride {
+			a, b := s.at(candidate+i), s.at(continuation+i)
+			score += a[0]*b[0] + a[1]*b[1]
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
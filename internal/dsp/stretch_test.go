@@ -0,0 +1,50 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep/v2"
+)
+
+// zeroCrossingRate estimates the frequency of a tone in the left channel.
+func zeroCrossingRate(samples [][2]float64, sampleRate beep.SampleRate) float64 {
+	crossings := 0
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1][0] < 0) != (samples[i][0] < 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / 2 / sampleRate.D(len(samples)).Seconds()
+}
+
+func TestTimeStretchChangesLengthButNotPitch(t *testing.T) {
+	const sampleRate = beep.SampleRate(44100)
+	n := sampleRate.N(2 * time.Second)
+	for _, speed := range []float64{0.5, 1.5, 2} {
+		out := drain(NewTimeStretch(sine(sampleRate, 440, 0.5, n), sampleRate, speed))
+
+		want := float64(n) / speed
+		if got := float64(len(out)); math.Abs(got-want) > float64(sampleRate.N(stretchFrame)) {
+			t.Fatalf("speed %v: output length = %v samples, want about %v", speed, got, want)
+		}
+		// Skip the fade-in of the first frame and the tail of the last.
+		edge := sampleRate.N(stretchFrame)
+		if got := zeroCrossingRate(out[edge:len(out)-edge], sampleRate); math.Abs(got-440) > 5 {
+			t.Fatalf("speed %v: tone = %.1f Hz, want 440", speed, got)
+		}
+	}
+}
+
+func TestTimeStretchStreamsWithoutAllocating(t *testing.T) {
+	const sampleRate = beep.SampleRate(44100)
+	for _, speed := range []float64{0.5, 2} {
+		stretch := NewTimeStretch(sine(sampleRate, 440, 0.5, math.MaxInt), sampleRate, speed)
+		buf := make([][2]float64, 512)
+		stretch.Stream(buf)
+		if allocs := testing.AllocsPerRun(100, func() { stretch.Stream(buf) }); allocs != 0 {
+			t.Fatalf("speed %v: Stream allocated %v times per call, want 0", speed, allocs)
+		}
+	}
+}
@@ -1,14 +1,16 @@
 package help
 
 import (
+	"errors"
 	"fmt"
-	"log"
-	"os"
 	"strings"
 
+	"charm.land/bubbles/v2/filepicker"
 	"charm.land/bubbles/v2/help"
 	"charm.land/bubbles/v2/key"
 	"charm.land/lipgloss/v2"
+
+	"github.com/kjloveless/tmp/internal/theme"
 )
 
 type FocusArea string
@@ -16,35 +18,123 @@ type FocusArea string
 const (
 	FocusTracks FocusArea = "tracks"
 	FocusQueue  FocusArea = "queue"
+	// FocusEqualizer is active while the equalizer editor replaces the
+	// sound map.
+	FocusEqualizer FocusArea = "equalizer"
 )
 
 type GlobalKeyMap struct {
-	PlayPause  key.Binding
-	SeekBack   key.Binding
-	SeekAhead  key.Binding
-	VolumeDown key.Binding
-	VolumeUp   key.Binding
-	Mute       key.Binding
-	FocusNext  key.Binding
-	Loop       key.Binding
-	Quit       key.Binding
-	KeyHelp    key.Binding
+	PlayPause      key.Binding
+	SeekBack       key.Binding
+	SeekAhead      key.Binding
+	SeekBackLarge  key.Binding
+	SeekAheadLarge key.Binding
+	JumpPercent    key.Binding
+	SeekTo         key.Binding
+	VolumeDown     key.Binding
+	VolumeUp       key.Binding
+	SpeedDown      key.Binding
+	SpeedUp        key.Binding
+	KeepPitch      key.Binding
+	Mute           key.Binding
+	FocusNext      key.Binding
+	Loop           key.Binding
+	Shuffle        key.Binding
+	Previous       key.Binding
+	Next           key.Binding
+	SavePlaylist   key.Binding
+	ABRepeat       key.Binding
+	Gapless        key.Binding
+	Crossfade      key.Binding
+	ReplayGain     key.Binding
+	Equalizer      key.Binding
+	EqEditor       key.Binding
+	Quit           key.Binding
+	KeyHelp        key.Binding
+}
+
+// bindings lists the global bindings in help display order.
+func (k GlobalKeyMap) bindings() []key.Binding {
+	return []key.Binding{
+		k.PlayPause,
+		k.SeekBack,
+		k.SeekAhead,
+		k.SeekBackLarge,
+		k.SeekAheadLarge,
+		k.JumpPercent,
+		k.SeekTo,
+		k.VolumeDown,
+		k.VolumeUp,
+		k.SpeedDown,
+		k.SpeedUp,
+		k.KeepPitch,
+		k.Mute,
+		k.FocusNext,
+		k.Loop,
+		k.Shuffle,
+		k.Previous,
+		k.Next,
+		k.SavePlaylist,
+		k.ABRepeat,
+		k.Gapless,
+		k.Crossfade,
+		k.ReplayGain,
+		k.Equalizer,
+		k.EqEditor,
+		k.Quit,
+		k.KeyHelp,
+	}
 }
 
 type TracksKeyMap struct {
-	QueueSelected key.Binding
+	QueueSelected  key.Binding
+	QueueNext      key.Binding
+	QueueDirectory key.Binding
+}
+
+func (k TracksKeyMap) bindings() []key.Binding {
+	return []key.Binding{k.QueueSelected, k.QueueNext, k.QueueDirectory}
+}
+
+// pickerBindings are the file picker's own keys, which the tracks pane only
+// sees once no global or tracks binding has matched.
+func pickerBindings() []key.Binding {
+	k := filepicker.DefaultKeyMap()
+	return []key.Binding{k.GoToTop, k.GoToLast, k.Down, k.Up, k.PageUp, k.PageDown, k.Back, k.Open, k.Select}
 }
 
 type QueueKeyMap struct {
 	DequeueSelected key.Binding
 	Up              key.Binding
 	Down            key.Binding
+	MoveUp          key.Binding
+	MoveDown        key.Binding
+	MoveToTop       key.Binding
+	History         key.Binding
+}
+
+func (k QueueKeyMap) bindings() []key.Binding {
+	return []key.Binding{k.Up, k.Down, k.MoveUp, k.MoveDown, k.MoveToTop, k.DequeueSelected, k.History}
+}
+
+// EqualizerKeyMap edits the bands while the equalizer editor is open. Its
+// keys share the keyboard with the global bindings, which take precedence.
+type EqualizerKeyMap struct {
+	PrevBand key.Binding
+	NextBand key.Binding
+	GainDown key.Binding
+	GainUp   key.Binding
+}
+
+func (k EqualizerKeyMap) bindings() []key.Binding {
+	return []key.Binding{k.PrevBand, k.NextBand, k.GainDown, k.GainUp}
 }
 
 type KeyMap struct {
-	Global GlobalKeyMap
-	Tracks TracksKeyMap
-	Queue  QueueKeyMap
+	Global    GlobalKeyMap
+	Tracks    TracksKeyMap
+	Queue     QueueKeyMap
+	Equalizer EqualizerKeyMap
 }
 
 var DefaultKeyMap = KeyMap{
@@ -61,6 +151,22 @@ var DefaultKeyMap = KeyMap{
 			key.WithKeys("right"),
 			key.WithHelp("→", "seek +5s"),
 		),
+		SeekBackLarge: key.NewBinding(
+			key.WithKeys("shift+left"),
+			key.WithHelp("shift+←", "seek -1m"),
+		),
+		SeekAheadLarge: key.NewBinding(
+			key.WithKeys("shift+right"),
+			key.WithHelp("shift+→", "seek +1m"),
+		),
+		JumpPercent: key.NewBinding(
+			key.WithKeys("0", "1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			key.WithHelp("0-9", "jump to 0-90%"),
+		),
+		SeekTo: key.NewBinding(
+			key.WithKeys(":"),
+			key.WithHelp(":", "go to time"),
+		),
 		VolumeDown: key.NewBinding(
 			key.WithKeys("-", "_"),
 			key.WithHelp("-", "volume down"),
@@ -69,6 +175,18 @@ var DefaultKeyMap = KeyMap{
 			key.WithKeys("=", "+"),
 			key.WithHelp("+", "volume up"),
 		),
+		SpeedDown: key.NewBinding(
+			key.WithKeys("<"),
+			key.WithHelp("<", "slower"),
+		),
+		SpeedUp: key.NewBinding(
+			key.WithKeys(">"),
+			key.WithHelp(">", "faster"),
+		),
+		KeepPitch: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "keep pitch"),
+		),
 		Mute: key.NewBinding(
 			key.WithKeys("m"),
 			key.WithHelp("m", "mute"),
@@ -81,6 +199,46 @@ var DefaultKeyMap = KeyMap{
 			key.WithKeys("l"),
 			key.WithHelp("l", "loop mode"),
 		),
+		Previous: key.NewBinding(
+			key.WithKeys("b"),
+			key.WithHelp("b", "previous"),
+		),
+		Next: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "next"),
+		),
+		SavePlaylist: key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "save playlist"),
+		),
+		Shuffle: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "shuffle"),
+		),
+		ABRepeat: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "a-b repeat"),
+		),
+		Gapless: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "gapless"),
+		),
+		Crossfade: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", "crossfade"),
+		),
+		ReplayGain: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "replaygain"),
+		),
+		Equalizer: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "eq preset"),
+		),
+		EqEditor: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", "eq editor"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("esc", "ctrl+c"),
 			key.WithHelp("esc/ctrl+c", "quit"),
@@ -95,6 +253,14 @@ var DefaultKeyMap = KeyMap{
 			key.WithKeys("q"),
 			key.WithHelp("q", "queue selected"),
 		),
+		QueueNext: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "play next"),
+		),
+		QueueDirectory: key.NewBinding(
+			key.WithKeys("Q"),
+			key.WithHelp("Q", "queue folder"),
+		),
 	},
 	Queue: QueueKeyMap{
 		DequeueSelected: key.NewBinding(
@@ -109,6 +275,40 @@ var DefaultKeyMap = KeyMap{
 			key.WithKeys("down", "j"),
 			key.WithHelp("↓/j", "move down"),
 		),
+		MoveUp: key.NewBinding(
+			key.WithKeys("shift+up", "K"),
+			key.WithHelp("⇧↑/K", "move item up"),
+		),
+		MoveDown: key.NewBinding(
+			key.WithKeys("shift+down", "J"),
+			key.WithHelp("⇧↓/J", "move item down"),
+		),
+		MoveToTop: key.NewBinding(
+			key.WithKeys("T"),
+			key.WithHelp("T", "move item to top"),
+		),
+		History: key.NewBinding(
+			key.WithKeys("h"),
+			key.WithHelp("h", "history"),
+		),
+	},
+	Equalizer: EqualizerKeyMap{
+		PrevBand: key.NewBinding(
+			key.WithKeys("["),
+			key.WithHelp("[", "previous band"),
+		),
+		NextBand: key.NewBinding(
+			key.WithKeys("]"),
+			key.WithHelp("]", "next band"),
+		),
+		GainDown: key.NewBinding(
+			key.WithKeys(","),
+			key.WithHelp(",", "band gain down"),
+		),
+		GainUp: key.NewBinding(
+			key.WithKeys("."),
+			key.WithHelp(".", "band gain up"),
+		),
 	},
 }
 
@@ -122,16 +322,18 @@ type Styles struct {
 	Separator    string
 }
 
+// DefaultStyles draws the help in the active theme.
 func DefaultStyles() Styles {
+	palette := theme.Active()
 	return Styles{
-		Title:        lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#89dceb")),
-		SectionTitle: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#f9e2af")),
-		Key:          lipgloss.NewStyle().Foreground(lipgloss.Color("#f5c2e7")).Bold(true),
-		Desc:         lipgloss.NewStyle().Foreground(lipgloss.Color("#a6adc8")),
+		Title:        lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(palette.Border)),
+		SectionTitle: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(palette.Accent)),
+		Key:          lipgloss.NewStyle().Foreground(lipgloss.Color(palette.Focus)).Bold(true),
+		Desc:         lipgloss.NewStyle().Foreground(lipgloss.Color(palette.Text)),
 		Row:          lipgloss.NewStyle().Padding(0, 1),
 		Panel: lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#94e2d5")).
+			BorderForeground(lipgloss.Color(palette.Info)).
 			Padding(1, 2),
 		Separator: "  —  ",
 	}
@@ -143,16 +345,22 @@ type HelpUI struct {
 	showHelp bool
 }
 
-func NewHelpUI(keys KeyMap) HelpUI {
-	validateOrPanic(keys)
+// NewHelpUI validates keys, which may come from the user's config.
+func NewHelpUI(keys KeyMap) (HelpUI, error) {
+	if err := Validate(keys); err != nil {
+		return HelpUI{}, err
+	}
 	return HelpUI{
 		model: help.New(),
 		keys:  keys,
-	}
+	}, nil
 }
 
 func NewDefault() HelpUI {
-	return NewHelpUI(DefaultKeyMap)
+	return HelpUI{
+		model: help.New(),
+		keys:  DefaultKeyMap,
+	}
 }
 
 func (hu HelpUI) Keys() KeyMap {
@@ -176,21 +384,13 @@ func (d displayKeyMap) ShortHelp() []key.Binding  { return d.short }
 func (d displayKeyMap) FullHelp() [][]key.Binding { return d.full }
 
 func (hu HelpUI) contextualBindings(focus FocusArea) []key.Binding {
-	bindings := []key.Binding{
-		hu.keys.Global.PlayPause,
-		hu.keys.Global.SeekBack,
-		hu.keys.Global.SeekAhead,
-		hu.keys.Global.VolumeDown,
-		hu.keys.Global.VolumeUp,
-		hu.keys.Global.Mute,
-		hu.keys.Global.FocusNext,
-		hu.keys.Global.Loop,
-		hu.keys.Global.Quit,
-		hu.keys.Global.KeyHelp,
-	}
-	if focus == FocusQueue {
+	bindings := hu.keys.Global.bindings()
+	switch focus {
+	case FocusEqualizer:
+		bindings = append(bindings, hu.keys.Equalizer.bindings()...)
+	case FocusQueue:
 		bindings = append(bindings, hu.keys.Queue.DequeueSelected)
-	} else {
+	default:
 		bindings = append(bindings, hu.keys.Tracks.QueueSelected)
 	}
 	return bindings
@@ -237,20 +437,10 @@ func (hu HelpUI) ListView(focus FocusArea) string {
 		return strings.Join(rows, "\n")
 	}
 
-	globalBindings := []key.Binding{
-		hu.keys.Global.PlayPause,
-		hu.keys.Global.SeekBack,
-		hu.keys.Global.SeekAhead,
-		hu.keys.Global.VolumeDown,
-		hu.keys.Global.VolumeUp,
-		hu.keys.Global.Mute,
-		hu.keys.Global.FocusNext,
-		hu.keys.Global.Loop,
-		hu.keys.Global.Quit,
-		hu.keys.Global.KeyHelp,
-	}
-	tracksBindings := []key.Binding{hu.keys.Tracks.QueueSelected}
-	queueBindings := []key.Binding{hu.keys.Queue.Up, hu.keys.Queue.Down, hu.keys.Queue.DequeueSelected}
+	globalBindings := hu.keys.Global.bindings()
+	tracksBindings := hu.keys.Tracks.bindings()
+	queueBindings := hu.keys.Queue.bindings()
+	equalizerBindings := hu.keys.Equalizer.bindings()
 
 	content := lipgloss.JoinVertical(
 		lipgloss.Left,
@@ -264,13 +454,21 @@ func (hu HelpUI) ListView(focus FocusArea) string {
 		"",
 		sectionTitle("Queue controls", focus == FocusQueue),
 		renderBindings(queueBindings),
+		"",
+		sectionTitle("Equalizer editor", focus == FocusEqualizer),
+		renderBindings(equalizerBindings),
 	)
 
 	return s.Panel.Width(w).Render(content)
 }
 
-func validateOrPanic(keys KeyMap) {
-	assertUnique := func(scope string, bindings []key.Binding) {
+// Validate reports keys bound twice where both bindings are live: within a
+// scope, and between the global bindings and a scope, since global keys are
+// matched first and would shadow the scoped ones. File picker bindings in the
+// tracks pane are reported once every one of their keys is shadowed.
+func Validate(keys KeyMap) error {
+	var errs []error
+	checkUnique := func(scope string, bindings []key.Binding) {
 		seen := make(map[string]string)
 		for _, b := range bindings {
 			h := b.Help()
@@ -279,28 +477,47 @@ func validateOrPanic(keys KeyMap) {
 			}
 			for _, k := range b.Keys() {
 				if prev, exists := seen[k]; exists {
-					msg := fmt.Sprintf("duplicate hotkey %q in %s: %s and %s", k, scope, prev, h.Desc)
-					fmt.Fprintln(os.Stdout, msg)
-					log.Print(msg)
-					panic(msg)
+					errs = append(errs, fmt.Errorf("key %q is bound twice in %s: %s and %s", k, scope, prev, h.Desc))
+					continue
 				}
 				seen[k] = h.Desc
 			}
 		}
 	}
 
-	assertUnique("global", []key.Binding{
-		keys.Global.PlayPause,
-		keys.Global.SeekBack,
-		keys.Global.SeekAhead,
-		keys.Global.VolumeDown,
-		keys.Global.VolumeUp,
-		keys.Global.Mute,
-		keys.Global.FocusNext,
-		keys.Global.Loop,
-		keys.Global.Quit,
-		keys.Global.KeyHelp,
-	})
-	assertUnique("tracks", []key.Binding{keys.Tracks.QueueSelected})
-	assertUnique("queue", []key.Binding{keys.Queue.Up, keys.Queue.Down, keys.Queue.DequeueSelected})
+	// The player has always taken some picker keys, such as the arrows for
+	// seeking, so a picker binding only breaks once none of its keys is left.
+	checkReachable := func(scope string, bindings, picker []key.Binding) {
+		taken := make(map[string]string)
+		for _, b := range bindings {
+			if b.Help().Key == "" {
+				continue
+			}
+			for _, k := range b.Keys() {
+				taken[k] = b.Help().Desc
+			}
+		}
+		for _, b := range picker {
+			free := false
+			for _, k := range b.Keys() {
+				if _, exists := taken[k]; !exists {
+					free = true
+				}
+			}
+			if free {
+				continue
+			}
+			for _, k := range b.Keys() {
+				errs = append(errs, fmt.Errorf("key %q is bound twice in %s: %s and file picker %s", k, scope, taken[k], b.Help().Desc))
+			}
+		}
+	}
+
+	tracks := append(keys.Global.bindings(), keys.Tracks.bindings()...)
+	checkUnique("global", keys.Global.bindings())
+	checkUnique("tracks", tracks)
+	checkReachable("tracks", tracks, pickerBindings())
+	checkUnique("queue", append(keys.Global.bindings(), keys.Queue.bindings()...))
+	checkUnique("equalizer", append(keys.Global.bindings(), keys.Equalizer.bindings()...))
+	return errors.Join(errs...)
 }
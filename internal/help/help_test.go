@@ -1,6 +1,7 @@
 package help
 
 import (
+	"strings"
 	"testing"
 
 	"charm.land/bubbles/v2/key"
@@ -34,18 +35,21 @@ func TestDefaultPlaybackBindingsExposeSeekAndMuteKeys(t *testing.T) {
 	}
 }
 
-func TestNewHelpUIPanicsOnDuplicateScopeBindings(t *testing.T) {
+func TestNewHelpUIReportsDuplicateScopeBindings(t *testing.T) {
 	keys := DefaultKeyMap
 	keys.Queue.Down = key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "down"))
 	keys.Queue.DequeueSelected = key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "dequeue"))
 
-	defer func() {
-		if recover() == nil {
-			t.Fatal("expected duplicate queue key binding panic")
-		}
-	}()
+	_, err := NewHelpUI(keys)
+	if err == nil || !strings.Contains(err.Error(), `key "d" is bound twice in queue: down and dequeue`) {
+		t.Fatalf("NewHelpUI() error = %v, want the duplicate queue key", err)
+	}
+}
 
-	_ = NewHelpUI(keys)
+func TestDefaultKeyMapIsValid(t *testing.T) {
+	if err := Validate(DefaultKeyMap); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestContextualBindingsIncludesFocusedComponent(t *testing.T) {
@@ -69,3 +73,78 @@ func TestContextualBindingsIncludesFocusedComponent(t *testing.T) {
 		}
 	}
 }
+
+func TestNewHelpUIReportsScopedKeysShadowedByGlobals(t *testing.T) {
+	keys := DefaultKeyMap
+	keys.Equalizer.GainUp = key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "band gain up"))
+	keys.Tracks.QueueNext = key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "play next"))
+	keys.Queue.MoveToTop = key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "move to top"))
+
+	_, err := NewHelpUI(keys)
+	if err == nil {
+		t.Fatal("expected scoped keys shadowed by global keys to be reported")
+	}
+	for _, want := range []string{
+		`key "p" is bound twice in equalizer: play/pause and band gain up`,
+		`key "m" is bound twice in tracks: mute and play next`,
+		`key "l" is bound twice in queue: loop mode and move to top`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestRemapRebindsActionsAndRelabelsHelp(t *testing.T) {
+	keys, err := DefaultKeyMap.Remap("global", map[string][]string{
+		"play_pause": {"space"},
+		"seek_back":  {"shift+h", "left"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys.Global.PlayPause.Keys(); len(got) != 1 || got[0] != "space" {
+		t.Fatalf("play/pause keys = %q, want space", got)
+	}
+	if got := keys.Global.SeekBack.Help(); got.Key != "shift+h/←" || got.Desc != "seek -5s" {
+		t.Fatalf("seek back help = %+v, want the new keys with the old description", got)
+	}
+	same, err := DefaultKeyMap.Remap("global", map[string][]string{"volume_up": {"=", "+"}})
+	if err != nil || same.Global.VolumeUp.Help().Key != "+" {
+		t.Fatalf("unchanged keys should keep their label, got %q (%v)", same.Global.VolumeUp.Help().Key, err)
+	}
+	if got := DefaultKeyMap.Global.PlayPause.Keys(); got[0] != "p" {
+		t.Fatal("Remap should not change the default key map")
+	}
+	if view := (HelpUI{keys: keys}).ListView(FocusTracks); !strings.Contains(view, "space") {
+		t.Fatalf("help view does not show the remapped key:\n%s", view)
+	}
+
+	for _, tc := range []struct {
+		scope     string
+		overrides map[string][]string
+		want      string
+	}{
+		{"global", map[string][]string{"play": {"x"}}, "keys.global.play: unknown action"},
+		{"tracks", map[string][]string{"queue_next": {}}, "keys.tracks.queue_next: needs at least one key"},
+		{"queue", map[string][]string{"up": {"a b"}}, `keys.queue.up: invalid key "a b"`},
+		{"player", map[string][]string{"up": {"u"}}, "keys.player: unknown key scope"},
+	} {
+		if _, err := DefaultKeyMap.Remap(tc.scope, tc.overrides); err == nil || err.Error() != tc.want {
+			t.Errorf("Remap(%s, %v) error = %v, want %q", tc.scope, tc.overrides, err, tc.want)
+		}
+	}
+}
+
+func TestValidateReportsFilePickerKeysShadowedInTracks(t *testing.T) {
+	keys, err := DefaultKeyMap.Remap("global", map[string][]string{"gapless": {"g"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Validate(keys)
+	want := `key "g" is bound twice in tracks: gapless and file picker first`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("Validate() error = %v, want %q", err, want)
+	}
+}
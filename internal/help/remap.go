@@ -0,0 +1,141 @@
+package help
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"charm.land/bubbles/v2/key"
+)
+
+// actions names the bindings after their fields in snake_case, the way they
+// are written in the config file.
+func (k *GlobalKeyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"play_pause":       &k.PlayPause,
+		"seek_back":        &k.SeekBack,
+		"seek_ahead":       &k.SeekAhead,
+		"seek_back_large":  &k.SeekBackLarge,
+		"seek_ahead_large": &k.SeekAheadLarge,
+		"jump_percent":     &k.JumpPercent,
+		"seek_to":          &k.SeekTo,
+		"volume_down":      &k.VolumeDown,
+		"volume_up":        &k.VolumeUp,
+		"speed_down":       &k.SpeedDown,
+		"speed_up":         &k.SpeedUp,
+		"keep_pitch":       &k.KeepPitch,
+		"mute":             &k.Mute,
+		"focus_next":       &k.FocusNext,
+		"loop":             &k.Loop,
+		"shuffle":          &k.Shuffle,
+		"previous":         &k.Previous,
+		"next":             &k.Next,
+		"save_playlist":    &k.SavePlaylist,
+		"ab_repeat":        &k.ABRepeat,
+		"gapless":          &k.Gapless,
+		"crossfade":        &k.Crossfade,
+		"replay_gain":      &k.ReplayGain,
+		"equalizer":        &k.Equalizer,
+		"eq_editor":        &k.EqEditor,
+		"quit":             &k.Quit,
+		"key_help":         &k.KeyHelp,
+	}
+}
+
+func (k *TracksKeyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"queue_selected":  &k.QueueSelected,
+		"queue_next":      &k.QueueNext,
+		"queue_directory": &k.QueueDirectory,
+	}
+}
+
+func (k *QueueKeyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"dequeue_selected": &k.DequeueSelected,
+		"up":               &k.Up,
+		"down":             &k.Down,
+		"move_up":          &k.MoveUp,
+		"move_down":        &k.MoveDown,
+		"move_to_top":      &k.MoveToTop,
+		"history":          &k.History,
+	}
+}
+
+func (k *KeyMap) scope(name string) (map[string]*key.Binding, bool) {
+	switch name {
+	case "global":
+		return k.Global.actions(), true
+	case "tracks":
+		return k.Tracks.actions(), true
+	case "queue":
+		return k.Queue.actions(), true
+	}
+	return nil, false
+}
+
+// Remap returns a copy of k with the named actions of scope ("global",
+// "tracks" or "queue") bound to new keys. Help labels follow the new keys.
+// Conflicts are left to Validate.
+func (k KeyMap) Remap(scope string, overrides map[string][]string) (KeyMap, error) {
+	actions, ok := k.scope(scope)
+	if !ok {
+		return KeyMap{}, fmt.Errorf("keys.%s: unknown key scope", scope)
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		binding, ok := actions[name]
+		if !ok {
+			return KeyMap{}, fmt.Errorf("keys.%s.%s: unknown action", scope, name)
+		}
+		keys := overrides[name]
+		if len(keys) == 0 {
+			return KeyMap{}, fmt.Errorf("keys.%s.%s: needs at least one key", scope, name)
+		}
+		for _, keyName := range keys {
+			if keyName == "" || strings.ContainsAny(keyName, " \t") {
+				return KeyMap{}, fmt.Errorf("keys.%s.%s: invalid key %q", scope, name, keyName)
+			}
+		}
+		// Unchanged keys keep their hand-written label.
+		if slices.Equal(keys, binding.Keys()) {
+			continue
+		}
+		binding.SetKeys(keys...)
+		binding.SetHelp(keyLabel(keys), binding.Help().Desc)
+	}
+	return k, nil
+}
+
+// Bindings lists the keys of every action in scope by action name, in the
+// form Remap accepts.
+func (k KeyMap) Bindings(scope string) map[string][]string {
+	actions, ok := k.scope(scope)
+	if !ok {
+		return nil
+	}
+	keys := make(map[string][]string, len(actions))
+	for name, binding := range actions {
+		keys[name] = slices.Clone(binding.Keys())
+	}
+	return keys
+}
+
+var arrowLabels = strings.NewReplacer("left", "←", "right", "→", "up", "↑", "down", "↓")
+
+// keyLabel is how a remapped binding is shown in help, e.g. "shift+←/K".
+func keyLabel(keys []string) string {
+	labels := make([]string, len(keys))
+	for i, k := range keys {
+		if len([]rune(k)) == 1 {
+			labels[i] = k
+			continue
+		}
+		labels[i] = arrowLabels.Replace(k)
+	}
+	return strings.Join(labels, "/")
+}
@@ -0,0 +1,109 @@
+package loudness
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kjloveless/tmp/internal/atomicfile"
+)
+
+type cacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Result  Result    `json:"result"`
+}
+
+// Cache remembers scan results per file, keyed by absolute path and
+// invalidated when the file's size or modification time changes. It is safe
+// for concurrent use and, when it has a path, persists itself as JSON after
+// every new entry.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]cacheEntry
+}
+
+// DefaultCachePath is the loudness cache under the user's cache directory.
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tmp", "loudness.json"), nil
+}
+
+// OpenCache loads the cache stored at path. A missing file yields an empty
+// cache; an empty path yields one that is never written.
+func OpenCache(path string) (*Cache, error) {
+	c := &Cache{path: path, entries: make(map[string]cacheEntry)}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		// A corrupt cache only costs a rescan.
+		c.entries = make(map[string]cacheEntry)
+	}
+	return c, nil
+}
+
+func (c *Cache) Get(path string) (Result, bool) {
+	key, info, err := cacheKey(path)
+	if err != nil {
+		return Result{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return Result{}, false
+	}
+	return entry.Result, true
+}
+
+func (c *Cache) Put(path string, result Result) error {
+	key, info, err := cacheKey(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{Size: info.Size(), ModTime: info.ModTime(), Result: result}
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	// Another player saving at the same time never leaves a truncated cache.
+	return atomicfile.Write(c.path, data, 0o644)
+}
+
+func cacheKey(path string) (string, fs.FileInfo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", nil, err
+	}
+	return abs, info, nil
+}
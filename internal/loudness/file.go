@@ -0,0 +1,31 @@
+package loudness
+
+import (
+	"github.com/kjloveless/tmp/internal/decode"
+)
+
+// ScanFile decodes the file at path and scans it, consulting cache first
+// when one is given.
+func ScanFile(path string, cache *Cache) (Result, error) {
+	if cache != nil {
+		if result, ok := cache.Get(path); ok {
+			return result, nil
+		}
+	}
+
+	streamer, format, err := decode.File(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer streamer.Close()
+
+	result, err := Scan(streamer, format)
+	if err != nil {
+		return Result{}, err
+	}
+	if cache != nil {
+		// Failing to persist the cache only costs a rescan next time.
+		_ = cache.Put(path, result)
+	}
+	return result, nil
+}
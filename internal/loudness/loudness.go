@@ -0,0 +1,182 @@
+package loudness
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gopxl/beep/v2"
+)
+
+const (
+	// ReferenceLUFS is the ReplayGain 2.0 target loudness.
+	ReferenceLUFS = -18.0
+
+	blockDuration   = 400 * time.Millisecond // gating block length
+	blocksPerWindow = 4                      // gating blocks overlap by 75%
+	absoluteGate    = -70.0
+	relativeGate    = -10.0
+	streamChunk     = 4096
+)
+
+var ErrSilent = errors.New("loudness: no audio above the absolute gate")
+
+// Result is the outcome of a loudness scan.
+type Result struct {
+	Integrated float64 // LUFS
+	Peak       float64 // largest absolute sample value
+}
+
+// Gain returns the ReplayGain-style adjustment in dB that brings the scanned
+// audio to ReferenceLUFS.
+func (r Result) Gain() float64 {
+	return ReferenceLUFS - r.Integrated
+}
+
+// Scan measures the integrated loudness of streamer as defined by EBU R128
+// (ITU-R BS.1770): K-weighted mean square over 400 ms blocks with 75%
+// overlap, gated at -70 LUFS and then 10 LU below the ungated mean. Mono
+// sources are measured on one channel so that beep's duplicated stereo does
+// not read 3 dB louder.
+func Scan(streamer beep.Streamer, format beep.Format) (Result, error) {
+	channels := 2
+	if format.NumChannels == 1 {
+		channels = 1
+	}
+	filters := make([]kWeighting, channels)
+	for i := range filters {
+		filters[i] = newKWeighting(float64(format.SampleRate))
+	}
+
+	hop := max(format.SampleRate.N(blockDuration/blocksPerWindow), 1)
+	var (
+		result   Result
+		hops     []float64 // summed channel energy of each quarter block
+		energy   float64
+		inHop    int
+		buffer   = make([][2]float64, streamChunk)
+		finished bool
+	)
+	for !finished {
+		n, ok := streamer.Stream(buffer)
+		for _, sample := range buffer[:n] {
+			for ch := 0; ch < channels; ch++ {
+				result.Peak = max(result.Peak, math.Abs(sample[ch]))
+				weighted := filters[ch].process(sample[ch])
+				energy += weighted * weighted
+			}
+			inHop++
+			if inHop == hop {
+				hops = append(hops, energy/float64(hop))
+				energy, inHop = 0, 0
+			}
+		}
+		finished = !ok
+	}
+	if err := streamer.Err(); err != nil {
+		return Result{}, err
+	}
+
+	var blocks []float64
+	for i := blocksPerWindow - 1; i < len(hops); i++ {
+		var sum float64
+		for _, e := range hops[i-blocksPerWindow+1 : i+1] {
+			sum += e
+		}
+		blocks = append(blocks, sum/blocksPerWindow)
+	}
+
+	gated := gate(blocks, absoluteGate)
+	if len(gated) == 0 {
+		return Result{Peak: result.Peak}, ErrSilent
+	}
+	relative := lufs(mean(gated)) + relativeGate
+	gated = gate(gated, relative)
+	if len(gated) == 0 {
+		return Result{Peak: result.Peak}, ErrSilent
+	}
+	result.Integrated = lufs(mean(gated))
+	return result, nil
+}
+
+func lufs(meanSquare float64) float64 {
+	if meanSquare <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+func gate(blocks []float64, threshold float64) []float64 {
+	var kept []float64
+	for _, b := range blocks {
+		if lufs(b) > threshold {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// biquad is a direct form I second-order filter with a0 normalized to 1.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting is the BS.1770 pre-filter (a high shelf modelling the head)
+// followed by the RLB high-pass, with coefficients derived for any sample
+// rate rather than the tabulated 48 kHz values.
+type kWeighting struct {
+	shelf, highpass biquad
+}
+
+func newKWeighting(sampleRate float64) kWeighting {
+	const (
+		shelfFreq = 1681.974450955533
+		shelfGain = 3.999843853973347
+		shelfQ    = 0.7071752369554196
+		passFreq  = 38.13547087602444
+		passQ     = 0.5003270373238773
+	)
+
+	k := math.Tan(math.Pi * shelfFreq / sampleRate)
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/shelfQ + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * passFreq / sampleRate)
+	a0 = 1 + k/passQ + k*k
+	highpass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/passQ + k*k) / a0,
+	}
+	return kWeighting{shelf: shelf, highpass: highpass}
+}
+
+func (k *kWeighting) process(x float64) float64 {
+	return k.highpass.process(k.shelf.process(x))
+}
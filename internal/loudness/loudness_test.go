@@ -0,0 +1,95 @@
+package loudness
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep/v2"
+)
+
+func sine(format beep.Format, freq, amplitude float64, d time.Duration) beep.Streamer {
+	position := 0
+	return beep.Take(format.SampleRate.N(d), beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			v := amplitude * math.Sin(2*math.Pi*freq*float64(position)/float64(format.SampleRate))
+			samples[i] = [2]float64{v, v}
+			position++
+		}
+		return len(samples), true
+	}))
+}
+
+func TestKWeightingMatchesBS1770At48kHz(t *testing.T) {
+	k := newKWeighting(48000)
+	want := []float64{1.53512485958697, -2.69169618940638, 1.19839281085285, -1.69065929318241, 0.73248077421585}
+	got := []float64{k.shelf.b0, k.shelf.b1, k.shelf.b2, k.shelf.a1, k.shelf.a2}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("shelf coefficient %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if math.Abs(k.highpass.a1+1.99004745483398) > 1e-9 || math.Abs(k.highpass.a2-0.99007225036621) > 1e-9 {
+		t.Fatalf("high-pass feedback = %v, %v", k.highpass.a1, k.highpass.a2)
+	}
+}
+
+func TestScanMeasuresReferenceTone(t *testing.T) {
+	// EBU Tech 3341 case 1: a stereo 1 kHz sine at -23 dBFS reads -23 LUFS.
+	for _, rate := range []beep.SampleRate{44100, 48000} {
+		format := beep.Format{SampleRate: rate, NumChannels: 2, Precision: 2}
+		result, err := Scan(sine(format, 1000, math.Pow(10, -23.0/20), 5*time.Second), format)
+		if err != nil {
+			t.Fatalf("scan at %d Hz: %v", rate, err)
+		}
+		if math.Abs(result.Integrated+23) > 0.1 {
+			t.Fatalf("integrated loudness at %d Hz = %.2f LUFS, want -23", rate, result.Integrated)
+		}
+		if math.Abs(result.Gain()-5) > 0.1 {
+			t.Fatalf("gain = %.2f dB, want +5 to reach the -18 LUFS reference", result.Gain())
+		}
+	}
+
+	format := beep.Format{SampleRate: 48000, NumChannels: 2, Precision: 2}
+	if _, err := Scan(sine(format, 1000, 0, time.Second), format); err != ErrSilent {
+		t.Fatalf("silent scan error = %v, want ErrSilent", err)
+	}
+}
+
+func TestCacheInvalidatesChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	audio := filepath.Join(dir, "song.wav")
+	if err := os.WriteFile(audio, []byte("one"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath := filepath.Join(dir, "cache", "loudness.json")
+	cache, err := OpenCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{Integrated: -14, Peak: 0.9}
+	if err := cache.Put(audio, want); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if entries, err := os.ReadDir(filepath.Dir(cachePath)); err != nil || len(entries) != 1 {
+		t.Fatalf("cache dir = %v, %v; want only the cache file left behind", entries, err)
+	}
+
+	reopened, err := OpenCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reopened.Get(audio); !ok || got != want {
+		t.Fatalf("cached result = %+v, %v; want %+v from disk", got, ok, want)
+	}
+
+	if err := os.WriteFile(audio, []byte("changed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get(audio); ok {
+		t.Fatal("cache should miss once the file changes")
+	}
+}
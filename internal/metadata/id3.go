@@ -0,0 +1,334 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	id3v2HeaderSize = 10
+	id3v1Size       = 128
+)
+
+// readID3v2 parses an ID3v2.2, v2.3 or v2.4 tag at the current offset of r.
+// A missing tag is not an error.
+func readID3v2(r io.Reader) (Tags, error) {
+	var tags Tags
+	err := walkID3v2(r, func(id string, frame []byte) bool {
+		applyID3Frame(&tags, id, frame)
+		return true
+	})
+	return tags, err
+}
+
+// walkID3v2 calls visit with the ID and payload of each frame until visit
+// returns false.
+func walkID3v2(r io.Reader, visit func(id string, frame []byte) bool) error {
+	header := make([]byte, id3v2HeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		return err
+	}
+	if !bytes.Equal(header[:3], []byte("ID3")) {
+		return nil
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := synchsafe(header[6:10])
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return err
+	}
+	if flags&0x80 != 0 && version < 4 {
+		body = removeUnsync(body)
+	}
+	if flags&0x40 != 0 && version >= 3 && len(body) >= 4 {
+		// Skip the extended header.
+		extended := int(binary.BigEndian.Uint32(body[:4]))
+		if version == 4 {
+			extended = synchsafe(body[:4])
+		} else {
+			extended += 4
+		}
+		if extended > len(body) {
+			return nil
+		}
+		body = body[extended:]
+	}
+
+	idLength, headerLength := 4, 10
+	if version == 2 {
+		idLength, headerLength = 3, 6
+	}
+
+	for len(body) >= headerLength {
+		id := string(body[:idLength])
+		if id[0] == 0 {
+			break // padding
+		}
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+		default:
+			frameSize = synchsafe(body[4:8])
+		}
+		var frameFlags byte
+		if version >= 3 {
+			frameFlags = body[9]
+		}
+		body = body[headerLength:]
+		if frameSize > len(body) {
+			break
+		}
+		frame := body[:frameSize]
+		body = body[frameSize:]
+
+		if version == 4 && frameFlags&0x02 != 0 {
+			frame = removeUnsync(frame)
+		}
+		if version >= 3 && frameFlags&0x0c != 0 {
+			// Compressed or encrypted frames are not worth the complexity here.
+			continue
+		}
+		if !visit(id, frame) {
+			break
+		}
+	}
+	return nil
+}
+
+func applyID3Frame(tags *Tags, id string, frame []byte) {
+	switch id {
+	case "TIT2", "TT2":
+		tags.Title = decodeID3Text(frame)
+	case "TPE1", "TP1":
+		tags.Artist = decodeID3Text(frame)
+	case "TPE2", "TP2":
+		if tags.Artist == "" {
+			tags.Artist = decodeID3Text(frame)
+		}
+	case "TALB", "TAL":
+		tags.Album = decodeID3Text(frame)
+	case "TRCK", "TRK":
+		tags.Track = parseNumber(decodeID3Text(frame))
+	case "TYER", "TYE", "TDRC":
+		tags.Year = parseNumber(decodeID3Text(frame))
+	case "TCON", "TCO":
+		tags.Genre = normalizeGenre(decodeID3Text(frame))
+	case "TXXX", "TXX":
+		if description, value, ok := decodeID3UserText(frame); ok {
+			tags.ReplayGain.set(description, value)
+		}
+	case "COMM", "COM":
+		if comment := decodeID3Comment(frame); comment != "" && tags.Comment == "" {
+			tags.Comment = comment
+		}
+	}
+}
+
+func decodeID3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+	text := decodeID3String(frame[0], frame[1:])
+	// v2.4 separates multiple values with NUL; keep the first.
+	if i := strings.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return cleanText(text)
+}
+
+// decodeID3Comment returns the text of a comment frame. Comments with a
+// description, such as iTunes' "iTunNORM" loudness data, are machine readable
+// and skipped.
+func decodeID3Comment(frame []byte) string {
+	if len(frame) < 4 {
+		return ""
+	}
+	encoding := frame[0]
+	rest := frame[4:]
+	terminator := []byte{0}
+	if encoding == 1 || encoding == 2 {
+		terminator = []byte{0, 0}
+	}
+	for i := 0; i+len(terminator) <= len(rest); i += len(terminator) {
+		if !bytes.Equal(rest[i:i+len(terminator)], terminator) {
+			continue
+		}
+		if cleanText(decodeID3String(encoding, rest[:i])) != "" {
+			return ""
+		}
+		return cleanText(decodeID3String(encoding, rest[i+len(terminator):]))
+	}
+	return ""
+}
+
+// decodeID3UserText splits a user-defined text frame into its description
+// and value.
+func decodeID3UserText(frame []byte) (string, string, bool) {
+	if len(frame) < 2 {
+		return "", "", false
+	}
+	encoding := frame[0]
+	rest := frame[1:]
+	terminator := []byte{0}
+	if encoding == 1 || encoding == 2 {
+		terminator = []byte{0, 0}
+	}
+	for i := 0; i+len(terminator) <= len(rest); i += len(terminator) {
+		if bytes.Equal(rest[i:i+len(terminator)], terminator) {
+			description := cleanText(decodeID3String(encoding, rest[:i]))
+			value := decodeID3Text(append([]byte{encoding}, rest[i+len(terminator):]...))
+			return description, value, true
+		}
+	}
+	return "", "", false
+}
+
+func decodeID3String(encoding byte, data []byte) string {
+	switch encoding {
+	case 1, 2:
+		return decodeUTF16(data, encoding == 2)
+	case 3:
+		return string(data)
+	default:
+		return decodeLatin1(data)
+	}
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xff && data[1] == 0xfe:
+			bigEndian = false
+			data = data[2:]
+		case data[0] == 0xfe && data[1] == 0xff:
+			bigEndian = true
+			data = data[2:]
+		}
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, binary.BigEndian.Uint16(data[i:]))
+		} else {
+			units = append(units, binary.LittleEndian.Uint16(data[i:]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func decodeLatin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func synchsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func removeUnsync(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xff && i+1 < len(data) && data[i+1] == 0x00 {
+			i++
+		}
+	}
+	return out
+}
+
+// readID3v1 parses the fixed 128-byte tag at the end of r, if present.
+func readID3v1(r io.ReadSeeker) (Tags, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Tags{}, err
+	}
+	if end < id3v1Size {
+		return Tags{}, nil
+	}
+	if _, err := r.Seek(end-id3v1Size, io.SeekStart); err != nil {
+		return Tags{}, err
+	}
+	tag := make([]byte, id3v1Size)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return Tags{}, err
+	}
+	if !bytes.Equal(tag[:3], []byte("TAG")) {
+		return Tags{}, nil
+	}
+
+	field := func(start, length int) string {
+		value := tag[start : start+length]
+		if i := bytes.IndexByte(value, 0); i >= 0 {
+			value = value[:i]
+		}
+		return cleanText(decodeLatin1(value))
+	}
+	tags := Tags{
+		Title:   field(3, 30),
+		Artist:  field(33, 30),
+		Album:   field(63, 30),
+		Year:    parseNumber(field(93, 4)),
+		Comment: field(97, 30),
+	}
+	// ID3v1.1 stores the track number in the last comment byte.
+	if tag[125] == 0 && tag[126] != 0 {
+		tags.Comment = field(97, 28)
+		tags.Track = int(tag[126])
+	}
+	if int(tag[127]) < len(id3v1Genres) {
+		tags.Genre = id3v1Genres[tag[127]]
+	}
+	return tags, nil
+}
+
+// normalizeGenre resolves ID3 references like "(17)" or "17" to genre names.
+func normalizeGenre(genre string) string {
+	trimmed := strings.TrimSpace(genre)
+	if strings.HasPrefix(trimmed, "(") {
+		if end := strings.IndexByte(trimmed, ')'); end > 1 {
+			if rest := strings.TrimSpace(trimmed[end+1:]); rest != "" {
+				return rest
+			}
+			trimmed = trimmed[1:end]
+		}
+	}
+	if n := parseNumber(trimmed); n > 0 || trimmed == "0" {
+		if n < len(id3v1Genres) {
+			return id3v1Genres[n]
+		}
+	}
+	return genre
+}
+
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"Alternative Rock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychedelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal",
+	"Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll",
+	"Hard Rock",
+}
@@ -0,0 +1,212 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kjloveless/tmp/internal/midi"
+)
+
+const sniffLength = 16
+
+type Tags struct {
+	Title      string
+	Artist     string
+	Album      string
+	Track      int
+	Year       int
+	Genre      string
+	Comment    string
+	ReplayGain ReplayGain
+}
+
+// ReplayGain holds gain adjustments in dB and peak sample values as stored
+// in REPLAYGAIN_* tags.
+type ReplayGain struct {
+	TrackGain float64
+	TrackPeak float64
+	AlbumGain float64
+	AlbumPeak float64
+	HasTrack  bool
+	HasAlbum  bool
+}
+
+// maxReplayGain bounds tagged gains in dB; real adjustments are a few dB,
+// so anything past it is a broken tag.
+const maxReplayGain = 50
+
+// set stores a REPLAYGAIN_* tag value. Unknown keys and unparsable,
+// non-finite or negative-peak values are ignored, and gains are clamped to
+// ±maxReplayGain.
+func (rg *ReplayGain) set(key, value string) {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "dB"))
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return
+	}
+	gain := max(-maxReplayGain, min(number, maxReplayGain))
+	switch strings.ToUpper(key) {
+	case "REPLAYGAIN_TRACK_GAIN":
+		rg.TrackGain, rg.HasTrack = gain, true
+	case "REPLAYGAIN_TRACK_PEAK":
+		if number >= 0 {
+			rg.TrackPeak = number
+		}
+	case "REPLAYGAIN_ALBUM_GAIN":
+		rg.AlbumGain, rg.HasAlbum = gain, true
+	case "REPLAYGAIN_ALBUM_PEAK":
+		if number >= 0 {
+			rg.AlbumPeak = number
+		}
+	}
+}
+
+func (t Tags) Empty() bool {
+	return t == Tags{}
+}
+
+// DisplayTitle is the one-line name used for now playing and queue entries,
+// falling back to fallback (usually the file name) when the title tag is
+// missing.
+func (t Tags) DisplayTitle(fallback string) string {
+	switch {
+	case t.Title != "" && t.Artist != "":
+		return t.Artist + " – " + t.Title
+	case t.Title != "":
+		return t.Title
+	default:
+		return fallback
+	}
+}
+
+// Details summarizes the album-level tags, e.g. "Album (1999) • #3 • Genre".
+func (t Tags) Details() string {
+	var parts []string
+	album := t.Album
+	if t.Year > 0 {
+		album = strings.TrimSpace(fmt.Sprintf("%s (%d)", album, t.Year))
+	}
+	if album != "" {
+		parts = append(parts, album)
+	}
+	if t.Track > 0 {
+		parts = append(parts, fmt.Sprintf("#%d", t.Track))
+	}
+	if t.Genre != "" {
+		parts = append(parts, t.Genre)
+	}
+	return strings.Join(parts, " • ")
+}
+
+// merge fills fields missing from t with values from other.
+func (t Tags) merge(other Tags) Tags {
+	if t.Title == "" {
+		t.Title = other.Title
+	}
+	if t.Artist == "" {
+		t.Artist = other.Artist
+	}
+	if t.Album == "" {
+		t.Album = other.Album
+	}
+	if t.Track == 0 {
+		t.Track = other.Track
+	}
+	if t.Year == 0 {
+		t.Year = other.Year
+	}
+	if t.Genre == "" {
+		t.Genre = other.Genre
+	}
+	if t.Comment == "" {
+		t.Comment = other.Comment
+	}
+	if !t.ReplayGain.HasTrack && !t.ReplayGain.HasAlbum {
+		t.ReplayGain = other.ReplayGain
+	}
+	return t
+}
+
+// Read returns the tags embedded in the audio file at path. Files without
+// tags return empty Tags and a nil error.
+func Read(path string) (Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Tags{}, err
+	}
+	defer f.Close()
+	return ReadFrom(f)
+}
+
+func ReadFrom(r io.ReadSeeker) (Tags, error) {
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Tags{}, err
+	}
+	header = header[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Tags{}, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("RIFF")):
+		return readRIFF(r)
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return readFLAC(r)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return readOgg(r)
+	case bytes.HasPrefix(header, []byte("MThd")):
+		file, err := midi.Parse(r)
+		if err != nil {
+			return Tags{}, err
+		}
+		return Tags{Title: latin1OrUTF8(file.Title), Comment: latin1OrUTF8(file.Copyright)}, nil
+	default:
+		return readMP3(r)
+	}
+}
+
+func readMP3(r io.ReadSeeker) (Tags, error) {
+	tags, err := readID3v2(r)
+	if err != nil {
+		return Tags{}, err
+	}
+	v1, err := readID3v1(r)
+	if err != nil {
+		return Tags{}, err
+	}
+	return tags.merge(v1), nil
+}
+
+// parseNumber reads the leading integer of values like "3/12" or "1999-04-01".
+func parseNumber(s string) int {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if end >= 0 {
+		s = s[:end]
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// latin1OrUTF8 decodes text from formats that predate Unicode, such as MIDI
+// meta events, which are commonly Latin-1 but may already be UTF-8.
+func latin1OrUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return decodeLatin1([]byte(s))
+}
+
+func cleanText(s string) string {
+	return strings.TrimSpace(strings.TrimRight(s, "\x00 "))
+}
@@ -0,0 +1,217 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+func id3v23Frame(id string, body []byte) []byte {
+	frame := make([]byte, 10, 10+len(body))
+	copy(frame, id)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(body)))
+	return append(frame, body...)
+}
+
+func utf16Text(s string) []byte {
+	text := []byte{1, 0xff, 0xfe}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		text = binary.LittleEndian.AppendUint16(text, unit)
+	}
+	return text
+}
+
+func id3v23Tag(frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	size := len(body)
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, body...)
+}
+
+func TestReadID3v2FramesAndFallBackToID3v1(t *testing.T) {
+	var file bytes.Buffer
+	file.Write(id3v23Tag(
+		id3v23Frame("TIT2", utf16Text("Café Song")),
+		id3v23Frame("TPE1", append([]byte{0}, "The Band"...)),
+		id3v23Frame("TRCK", append([]byte{3}, "4/12"...)),
+		id3v23Frame("TCON", append([]byte{0}, "(17)"...)),
+		id3v23Frame("COMM", append([]byte{0, 'e', 'n', 'g', 'i', 'T', 'u', 'n', 'N', 'O', 'R', 'M', 0}, " 0000 0001"...)),
+		id3v23Frame("COMM", append([]byte{0, 'e', 'n', 'g', 0}, "liner notes"...)),
+		id3v23Frame("TXXX", append([]byte{0}, "REPLAYGAIN_TRACK_GAIN\x00-7.25 dB"...)),
+		id3v23Frame("TXXX", append([]byte{0}, "replaygain_track_peak\x00 0.988"...)),
+	))
+	file.Write([]byte{0xff, 0xfb, 0x90, 0x00}) // audio
+
+	v1 := make([]byte, id3v1Size)
+	copy(v1, "TAG")
+	copy(v1[3:], "Ignored Title")
+	copy(v1[63:], "Greatest Hits")
+	copy(v1[93:], "1999")
+	file.Write(v1)
+
+	tags, err := ReadFrom(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		t.Fatalf("read tags: %v", err)
+	}
+	want := Tags{
+		Title:   "Café Song",
+		Artist:  "The Band",
+		Album:   "Greatest Hits",
+		Track:   4,
+		Year:    1999,
+		Genre:   "Rock",
+		Comment: "liner notes",
+		ReplayGain: ReplayGain{
+			TrackGain: -7.25,
+			TrackPeak: 0.988,
+			HasTrack:  true,
+		},
+	}
+	if tags != want {
+		t.Fatalf("expected %+v, got %+v", want, tags)
+	}
+	if got := tags.DisplayTitle("song.mp3"); got != "The Band – Café Song" {
+		t.Fatalf("unexpected display title %q", got)
+	}
+	if got := tags.Details(); got != "Greatest Hits (1999) • #4 • Rock" {
+		t.Fatalf("unexpected details %q", got)
+	}
+}
+
+func TestReadRIFFInfoChunk(t *testing.T) {
+	subchunk := func(id, value string) []byte {
+		data := append([]byte(value), 0)
+		chunk := binary.LittleEndian.AppendUint32([]byte(id), uint32(len(data)))
+		chunk = append(chunk, data...)
+		if len(data)%2 == 1 {
+			chunk = append(chunk, 0)
+		}
+		return chunk
+	}
+	info := append([]byte("INFO"), bytes.Join([][]byte{
+		subchunk("INAM", "Chime"),
+		subchunk("IART", "Someone"),
+		subchunk("ICRD", "2001-05-06"),
+		subchunk("IGNR", "Ambient"),
+	}, nil)...)
+
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	body.WriteString("fmt ")
+	body.Write(binary.LittleEndian.AppendUint32(nil, 16))
+	body.Write(make([]byte, 16))
+	body.WriteString("LIST")
+	body.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(info))))
+	body.Write(info)
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(body.Len())))
+	file.Write(body.Bytes())
+
+	tags, err := ReadFrom(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		t.Fatalf("read tags: %v", err)
+	}
+	want := Tags{Title: "Chime", Artist: "Someone", Year: 2001, Genre: "Ambient"}
+	if tags != want {
+		t.Fatalf("expected %+v, got %+v", want, tags)
+	}
+}
+
+func TestReadFLACVorbisComment(t *testing.T) {
+	comment := binary.LittleEndian.AppendUint32(nil, 4)
+	comment = append(comment, "test"...)
+	comment = binary.LittleEndian.AppendUint32(comment, 4)
+	for _, field := range []string{"TITLE=Lossless", "artist=Encoder", "TRACKNUMBER=07", "REPLAYGAIN_ALBUM_GAIN=+2.10 dB"} {
+		comment = binary.LittleEndian.AppendUint32(comment, uint32(len(field)))
+		comment = append(comment, field...)
+	}
+
+	var file bytes.Buffer
+	file.WriteString("fLaC")
+	file.Write([]byte{0, 0, 0, 34}) // STREAMINFO
+	file.Write(make([]byte, 34))
+	file.Write([]byte{0x80 | flacVorbisCommentBlock, 0, byte(len(comment) >> 8), byte(len(comment))})
+	file.Write(comment)
+
+	tags, err := ReadFrom(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		t.Fatalf("read tags: %v", err)
+	}
+	want := Tags{
+		Title:      "Lossless",
+		Artist:     "Encoder",
+		Track:      7,
+		ReplayGain: ReplayGain{AlbumGain: 2.1, HasAlbum: true},
+	}
+	if tags != want {
+		t.Fatalf("expected %+v, got %+v", want, tags)
+	}
+}
+
+func TestReplayGainRejectsNonFiniteAndClampsGain(t *testing.T) {
+	var rg ReplayGain
+	rg.set("REPLAYGAIN_TRACK_GAIN", "NaN dB")
+	rg.set("REPLAYGAIN_ALBUM_GAIN", "+Inf")
+	rg.set("REPLAYGAIN_TRACK_PEAK", "-0.5")
+	if rg != (ReplayGain{}) {
+		t.Fatalf("expected broken tags to be ignored, got %+v", rg)
+	}
+
+	rg.set("REPLAYGAIN_TRACK_GAIN", "1e308 dB")
+	rg.set("REPLAYGAIN_ALBUM_GAIN", "-90 dB")
+	rg.set("REPLAYGAIN_ALBUM_PEAK", "0.98")
+	want := ReplayGain{TrackGain: 50, AlbumGain: -50, AlbumPeak: 0.98, HasTrack: true, HasAlbum: true}
+	if rg != want {
+		t.Fatalf("expected %+v, got %+v", want, rg)
+	}
+}
+
+func TestReadBundledFiles(t *testing.T) {
+	tests := []struct {
+		path string
+		want Tags
+	}{
+		{"../../sounds/mp3/save.mp3", Tags{Title: "SaveUpd_673", Comment: "MS18B-000-017_UI_673b20_bnc"}},
+		{"../../sounds/wav/flourish.mid", Tags{Title: "Flourish", Comment: "©2000 Microsoft Corporation"}},
+		{"../../sounds/wav/ding.wav", Tags{}},
+	}
+	for _, tt := range tests {
+		tags, err := Read(tt.path)
+		if err != nil {
+			t.Fatalf("read %s: %v", tt.path, err)
+		}
+		if tags != tt.want {
+			t.Fatalf("%s: expected %+v, got %+v", tt.path, tt.want, tags)
+		}
+		if tt.want.Empty() && tags.DisplayTitle("ding.wav") != "ding.wav" {
+			t.Fatalf("expected untagged file to fall back to its name")
+		}
+	}
+}
+
+func TestPictureFromPrefersFrontCover(t *testing.T) {
+	apic := func(kind byte, data string) []byte {
+		frame := append([]byte{0}, "image/png\x00"...)
+		frame = append(frame, kind)
+		frame = append(frame, "desc\x00"...)
+		return id3v23Frame("APIC", append(frame, data...))
+	}
+	tag := id3v23Tag(apic(4, "back"), apic(frontCoverType, "front"))
+
+	data, err := PictureFrom(bytes.NewReader(tag))
+	if err != nil {
+		t.Fatalf("read picture: %v", err)
+	}
+	if string(data) != "front" {
+		t.Fatalf("picture = %q, want front cover", data)
+	}
+
+	data, err = PictureFrom(bytes.NewReader([]byte("no tags at all")))
+	if err != nil || data != nil {
+		t.Fatalf("untagged picture = %q, %v; want nil, nil", data, err)
+	}
+}
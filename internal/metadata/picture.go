@@ -0,0 +1,149 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+const (
+	flacPictureBlock = 6
+	frontCoverType   = 3
+)
+
+// ReadPicture returns the embedded cover art of the audio file at path, as
+// stored in ID3 APIC/PIC frames or FLAC PICTURE blocks. The front cover is
+// preferred over other picture types. Files without a picture return nil data
+// and a nil error.
+func ReadPicture(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return PictureFrom(f)
+}
+
+func PictureFrom(r io.ReadSeeker) ([]byte, error) {
+	header := make([]byte, sniffLength)
+	n, _ := io.ReadFull(r, header)
+	header = header[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var picture pictureChoice
+	var err error
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		err = walkID3v2(r, func(id string, frame []byte) bool {
+			switch id {
+			case "APIC":
+				picture.offer(parseAPIC(frame))
+			case "PIC":
+				picture.offer(parsePIC(frame))
+			}
+			return !picture.front
+		})
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		err = walkFLAC(r, func(blockType byte, body []byte) bool {
+			if blockType == flacPictureBlock {
+				picture.offer(parseFLACPicture(body))
+			}
+			return !picture.front
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return picture.data, nil
+}
+
+// pictureChoice keeps the first picture seen, replacing it once with a front
+// cover.
+type pictureChoice struct {
+	data  []byte
+	front bool
+}
+
+func (c *pictureChoice) offer(kind byte, data []byte) {
+	if len(data) == 0 || c.front {
+		return
+	}
+	if c.data == nil || kind == frontCoverType {
+		c.data = data
+		c.front = kind == frontCoverType
+	}
+}
+
+// parseAPIC splits an ID3v2.3/2.4 picture frame: encoding, NUL-terminated
+// MIME type, picture type, description in the frame's encoding, then data.
+func parseAPIC(frame []byte) (byte, []byte) {
+	if len(frame) < 2 {
+		return 0, nil
+	}
+	encoding := frame[0]
+	mimeEnd := bytes.IndexByte(frame[1:], 0)
+	if mimeEnd < 0 || 1+mimeEnd+2 > len(frame) {
+		return 0, nil
+	}
+	rest := frame[1+mimeEnd+1:]
+	return rest[0], skipDescription(encoding, rest[1:])
+}
+
+// parsePIC splits an ID3v2.2 picture frame, which uses a three character
+// image format instead of a MIME type.
+func parsePIC(frame []byte) (byte, []byte) {
+	if len(frame) < 5 {
+		return 0, nil
+	}
+	return frame[4], skipDescription(frame[0], frame[5:])
+}
+
+func skipDescription(encoding byte, data []byte) []byte {
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				return data[i+2:]
+			}
+		}
+		return nil
+	}
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		return data[end+1:]
+	}
+	return nil
+}
+
+// parseFLACPicture reads a METADATA_BLOCK_PICTURE: type, MIME type and
+// description as length-prefixed strings, four dimension fields, then the
+// length-prefixed image data. All integers are big-endian.
+func parseFLACPicture(body []byte) (byte, []byte) {
+	next := func(n int) []byte {
+		if n < 0 || n > len(body) {
+			body = nil
+			return nil
+		}
+		field := body[:n]
+		body = body[n:]
+		return field
+	}
+	length := func() int {
+		field := next(4)
+		if field == nil {
+			return -1
+		}
+		return int(binary.BigEndian.Uint32(field))
+	}
+
+	kind := length()
+	next(length()) // MIME type
+	next(length()) // description
+	next(16)       // width, height, depth, palette size
+	data := next(length())
+	if kind < 0 || data == nil {
+		return 0, nil
+	}
+	return byte(kind), data
+}
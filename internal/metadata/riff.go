@@ -0,0 +1,92 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// readRIFF parses LIST/INFO chunks and embedded "id3 " chunks of a WAV file.
+func readRIFF(r io.ReadSeeker) (Tags, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Tags{}, err
+	}
+
+	var info, id3 Tags
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return Tags{}, err
+		}
+		id := string(chunk[:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		padded := size + size&1
+
+		switch id {
+		case "LIST", "id3 ", "ID3 ":
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return Tags{}, err
+			}
+			if id == "LIST" {
+				info = info.merge(parseINFO(body))
+			} else {
+				tags, err := readID3v2(bytes.NewReader(body))
+				if err != nil {
+					return Tags{}, err
+				}
+				id3 = id3.merge(tags)
+			}
+			if _, err := r.Seek(padded-size, io.SeekCurrent); err != nil {
+				return Tags{}, err
+			}
+		default:
+			if _, err := r.Seek(padded, io.SeekCurrent); err != nil {
+				return Tags{}, err
+			}
+		}
+	}
+	return info.merge(id3), nil
+}
+
+func parseINFO(list []byte) Tags {
+	var tags Tags
+	if len(list) < 4 || string(list[:4]) != "INFO" {
+		return tags
+	}
+
+	list = list[4:]
+	for len(list) >= 8 {
+		id := string(list[:4])
+		size := int(binary.LittleEndian.Uint32(list[4:8]))
+		list = list[8:]
+		if size > len(list) {
+			break
+		}
+		value := cleanText(string(list[:size]))
+		list = list[min(size+size&1, len(list)):]
+
+		switch id {
+		case "INAM":
+			tags.Title = value
+		case "IART":
+			tags.Artist = value
+		case "IPRD":
+			tags.Album = value
+		case "ITRK", "IPRT", "TRCK":
+			tags.Track = parseNumber(value)
+		case "ICRD":
+			tags.Year = parseNumber(value)
+		case "IGNR":
+			tags.Genre = value
+		case "ICMT":
+			tags.Comment = value
+		}
+	}
+	return tags
+}
@@ -0,0 +1,155 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	flacVorbisCommentBlock = 4
+	maxOggHeaderPages      = 16
+)
+
+func readFLAC(r io.Reader) (Tags, error) {
+	var tags Tags
+	err := walkFLAC(r, func(blockType byte, body []byte) bool {
+		if blockType != flacVorbisCommentBlock {
+			return true
+		}
+		tags = parseVorbisComment(body)
+		return false
+	})
+	return tags, err
+}
+
+// walkFLAC calls visit with each metadata block until visit returns false or
+// the last block has been read. Audio frames are never read.
+func walkFLAC(r io.Reader, visit func(blockType byte, body []byte) bool) error {
+	if _, err := io.ReadFull(r, make([]byte, 4)); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return err
+		}
+		if !visit(blockType, body) || last {
+			return nil
+		}
+	}
+}
+
+// readOgg reassembles the second packet of the first logical stream, which
+// holds the Vorbis comment header.
+func readOgg(r io.Reader) (Tags, error) {
+	var (
+		packets [][]byte
+		current []byte
+	)
+	header := make([]byte, 27)
+	for page := 0; page < maxOggHeaderPages && len(packets) < 2; page++ {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return Tags{}, err
+		}
+		if string(header[:4]) != "OggS" {
+			break
+		}
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return Tags{}, err
+		}
+		for _, length := range segments {
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return Tags{}, err
+			}
+			current = append(current, data...)
+			if length < 255 {
+				packets = append(packets, current)
+				current = nil
+			}
+		}
+	}
+
+	if len(packets) < 2 || !bytes.HasPrefix(packets[1], []byte("\x03vorbis")) {
+		return Tags{}, nil
+	}
+	return parseVorbisComment(packets[1][7:]), nil
+}
+
+func parseVorbisComment(data []byte) Tags {
+	var tags Tags
+	next := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		length := int(binary.LittleEndian.Uint32(data))
+		data = data[4:]
+		if length > len(data) {
+			return "", false
+		}
+		value := string(data[:length])
+		data = data[length:]
+		return value, true
+	}
+
+	if _, ok := next(); !ok { // vendor string
+		return tags
+	}
+	if len(data) < 4 {
+		return tags
+	}
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+	for i := 0; i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			break
+		}
+		key, value, found := strings.Cut(comment, "=")
+		if !found {
+			continue
+		}
+		value = cleanText(value)
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			tags.Title = value
+		case "ARTIST":
+			tags.Artist = value
+		case "ALBUMARTIST":
+			if tags.Artist == "" {
+				tags.Artist = value
+			}
+		case "ALBUM":
+			tags.Album = value
+		case "TRACKNUMBER":
+			tags.Track = parseNumber(value)
+		case "DATE", "YEAR":
+			tags.Year = parseNumber(value)
+		case "GENRE":
+			tags.Genre = value
+		case "COMMENT", "DESCRIPTION":
+			if tags.Comment == "" {
+				tags.Comment = value
+			}
+		default:
+			tags.ReplayGain.set(key, value)
+		}
+	}
+	return tags
+}
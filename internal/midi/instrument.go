@@ -0,0 +1,130 @@
+package midi
+
+import "math"
+
+type waveform int
+
+const (
+	waveSine waveform = iota
+	waveTriangle
+	waveSquare
+	waveSaw
+	waveOrgan
+	wavePiano
+	waveBell
+	waveNoise
+)
+
+// instrument is a single-oscillator patch with an ADSR envelope. Times are in
+// seconds and sustain is a level in [0, 1].
+type instrument struct {
+	wave    waveform
+	attack  float64
+	decay   float64
+	sustain float64
+	release float64
+	gain    float64
+}
+
+// gmFamilies approximates each block of eight General MIDI programs.
+var gmFamilies = [16]instrument{
+	{wave: wavePiano, attack: 0.004, decay: 0.9, sustain: 0, release: 0.3, gain: 0.9},     // piano
+	{wave: waveBell, attack: 0.002, decay: 0.7, sustain: 0, release: 0.5, gain: 0.7},      // chromatic percussion
+	{wave: waveOrgan, attack: 0.01, decay: 0.1, sustain: 0.9, release: 0.08, gain: 0.5},   // organ
+	{wave: waveSaw, attack: 0.003, decay: 0.5, sustain: 0, release: 0.15, gain: 0.55},     // guitar
+	{wave: waveTriangle, attack: 0.005, decay: 0.4, sustain: 0.6, release: 0.1, gain: 1},  // bass
+	{wave: waveSaw, attack: 0.12, decay: 0.3, sustain: 0.8, release: 0.3, gain: 0.4},      // strings
+	{wave: waveSaw, attack: 0.15, decay: 0.3, sustain: 0.8, release: 0.4, gain: 0.35},     // ensemble
+	{wave: waveSaw, attack: 0.04, decay: 0.2, sustain: 0.7, release: 0.15, gain: 0.45},    // brass
+	{wave: waveSquare, attack: 0.03, decay: 0.2, sustain: 0.75, release: 0.1, gain: 0.35}, // reed
+	{wave: waveSine, attack: 0.05, decay: 0.2, sustain: 0.8, release: 0.15, gain: 0.8},    // pipe
+	{wave: waveSquare, attack: 0.01, decay: 0.2, sustain: 0.7, release: 0.1, gain: 0.35},  // synth lead
+	{wave: waveTriangle, attack: 0.3, decay: 0.5, sustain: 0.8, release: 0.6, gain: 0.6},  // synth pad
+	{wave: waveTriangle, attack: 0.1, decay: 1, sustain: 0.5, release: 0.5, gain: 0.5},    // synth effects
+	{wave: waveSaw, attack: 0.003, decay: 0.6, sustain: 0, release: 0.2, gain: 0.5},       // ethnic
+	{wave: waveSine, attack: 0.002, decay: 0.3, sustain: 0, release: 0.2, gain: 0.8},      // percussive
+	{wave: waveNoise, attack: 0.05, decay: 0.5, sustain: 0.3, release: 0.3, gain: 0.3},    // sound effects
+}
+
+func melodicInstrument(program int) instrument {
+	return gmFamilies[(program&0x7f)/8]
+}
+
+// drum is a percussion-channel sound. Drums ignore note-off and ring out over
+// decay seconds.
+type drum struct {
+	pitch    float64
+	sweep    float64
+	decay    float64
+	noiseMix float64
+	highpass bool
+	gain     float64
+}
+
+func percussionSound(note int) drum {
+	switch note {
+	case 35, 36:
+		return drum{pitch: 50, sweep: 110, decay: 0.35, gain: 1.2}
+	case 38, 40:
+		return drum{pitch: 190, sweep: 40, decay: 0.18, noiseMix: 0.7, gain: 0.8}
+	case 37, 39:
+		return drum{decay: 0.12, noiseMix: 1, gain: 0.6}
+	case 42, 44:
+		return drum{decay: 0.05, noiseMix: 1, highpass: true, gain: 0.35}
+	case 46:
+		return drum{decay: 0.3, noiseMix: 1, highpass: true, gain: 0.3}
+	case 49, 51, 52, 53, 55, 57, 59:
+		return drum{decay: 1.1, noiseMix: 1, highpass: true, gain: 0.3}
+	case 41, 43, 45, 47, 48, 50:
+		// Toms step up in pitch from floor to high.
+		return drum{pitch: 80 + float64(note-41)*14, sweep: 60, decay: 0.3, gain: 0.9}
+	default:
+		return drum{pitch: 400 + float64(note%12)*60, decay: 0.08, noiseMix: 0.2, gain: 0.5}
+	}
+}
+
+func noteFrequency(note int, bend float64) float64 {
+	return 440 * math.Pow(2, (float64(note)-69+bend)/12)
+}
+
+// oscillate evaluates a waveform at phase (in cycles) in the range [-1, 1].
+func oscillate(wave waveform, phase float64, noise float64) float64 {
+	_, frac := math.Modf(phase)
+	switch wave {
+	case waveTriangle:
+		return 4*math.Abs(frac-0.5) - 1
+	case waveSquare:
+		if frac < 0.5 {
+			return 0.8
+		}
+		return -0.8
+	case waveSaw:
+		return 2*frac - 1
+	case waveOrgan:
+		return 0.6*math.Sin(2*math.Pi*phase) +
+			0.3*math.Sin(4*math.Pi*phase) +
+			0.15*math.Sin(6*math.Pi*phase)
+	case wavePiano:
+		return 0.7*math.Sin(2*math.Pi*phase) +
+			0.2*math.Sin(4*math.Pi*phase) +
+			0.1*math.Sin(6*math.Pi*phase)
+	case waveBell:
+		return 0.7*math.Sin(2*math.Pi*phase) + 0.3*math.Sin(2*math.Pi*phase*3.5)
+	case waveNoise:
+		return noise
+	default:
+		return math.Sin(2 * math.Pi * phase)
+	}
+}
+
+// noiseAt returns deterministic white noise for a sample index so that
+// rendering after a seek matches uninterrupted playback.
+func noiseAt(seed uint64, index int) float64 {
+	x := seed + uint64(index)*0x9e3779b97f4a7c15
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11)/float64(1<<52) - 1
+}
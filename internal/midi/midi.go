@@ -0,0 +1,323 @@
+package midi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTempo      = 500000 // microseconds per quarter note (120 BPM)
+	PercussionChannel = 9
+)
+
+type EventKind int
+
+const (
+	NoteOff EventKind = iota
+	NoteOn
+	ControlChange
+	ProgramChange
+	PitchBend
+)
+
+// Event is a channel message with its absolute time from the start of the
+// song. Data1 and Data2 carry the raw message bytes, except for PitchBend
+// where Data1 holds the full 14-bit value.
+type Event struct {
+	Time    time.Duration
+	Kind    EventKind
+	Channel int
+	Data1   int
+	Data2   int
+}
+
+type File struct {
+	Format    int
+	Tracks    int
+	Title     string
+	Copyright string
+	Events    []Event
+	Length    time.Duration
+}
+
+type rawEvent struct {
+	tick  uint64
+	tempo int
+	event Event
+	meta  bool
+}
+
+// trackText holds the text meta events of a single track.
+type trackText struct {
+	name      string
+	copyright string
+}
+
+func Parse(r io.Reader) (*File, error) {
+	br := bufio.NewReader(r)
+
+	id, body, err := readChunk(br)
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if id != "MThd" || len(body) < 6 {
+		return nil, errors.New("missing MThd header")
+	}
+	format := int(binary.BigEndian.Uint16(body[0:2]))
+	trackCount := int(binary.BigEndian.Uint16(body[2:4]))
+	division := binary.BigEndian.Uint16(body[4:6])
+	if format > 2 {
+		return nil, fmt.Errorf("unsupported format %d", format)
+	}
+
+	file := &File{Format: format, Tracks: trackCount}
+	var raw []rawEvent
+	for parsed := 0; parsed < trackCount; {
+		id, body, err := readChunk(br)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read track %d: %w", parsed, err)
+		}
+		if id != "MTrk" {
+			continue
+		}
+
+		var offset uint64
+		if format == 2 && len(raw) > 0 {
+			// Format 2 tracks are independent patterns; play them back to back.
+			offset = raw[len(raw)-1].tick
+		}
+		events, text, err := parseTrack(body, offset)
+		if err != nil {
+			return nil, fmt.Errorf("track %d: %w", parsed, err)
+		}
+		// The first track's name is the song title by convention.
+		if parsed == 0 {
+			file.Title = text.name
+		}
+		if file.Copyright == "" {
+			file.Copyright = text.copyright
+		}
+		raw = append(raw, events...)
+		parsed++
+	}
+
+	sort.SliceStable(raw, func(i, j int) bool {
+		if raw[i].tick != raw[j].tick {
+			return raw[i].tick < raw[j].tick
+		}
+		// Tempo changes apply before anything else on the same tick.
+		return raw[i].meta && !raw[j].meta
+	})
+
+	clock := newClock(division)
+	for _, ev := range raw {
+		at := clock.at(ev.tick)
+		if ev.meta {
+			clock.setTempo(ev.tick, ev.tempo)
+			continue
+		}
+		ev.event.Time = at
+		file.Events = append(file.Events, ev.event)
+	}
+	if len(raw) > 0 {
+		file.Length = clock.at(raw[len(raw)-1].tick)
+	}
+	return file, nil
+}
+
+func readChunk(r io.Reader) (string, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", nil, err
+	}
+	size := binary.BigEndian.Uint32(header[4:])
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return "", nil, err
+	}
+	return string(header[:4]), body, nil
+}
+
+func parseTrack(data []byte, tick uint64) ([]rawEvent, trackText, error) {
+	var (
+		events  []rawEvent
+		text    trackText
+		running byte
+		pos     int
+	)
+
+	readByte := func() (byte, error) {
+		if pos >= len(data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		b := data[pos]
+		pos++
+		return b, nil
+	}
+	readVarLen := func() (uint64, error) {
+		var value uint64
+		for i := 0; i < 4; i++ {
+			b, err := readByte()
+			if err != nil {
+				return 0, err
+			}
+			value = value<<7 | uint64(b&0x7f)
+			if b&0x80 == 0 {
+				return value, nil
+			}
+		}
+		return 0, errors.New("variable-length quantity too long")
+	}
+
+	for pos < len(data) {
+		delta, err := readVarLen()
+		if err != nil {
+			return nil, text, err
+		}
+		tick += delta
+
+		status, err := readByte()
+		if err != nil {
+			return nil, text, err
+		}
+		switch {
+		case status == 0xff:
+			kind, err := readByte()
+			if err != nil {
+				return nil, text, err
+			}
+			length, err := readVarLen()
+			if err != nil {
+				return nil, text, err
+			}
+			if uint64(len(data)-pos) < length {
+				return nil, text, io.ErrUnexpectedEOF
+			}
+			payload := data[pos : pos+int(length)]
+			pos += int(length)
+
+			switch {
+			case kind == 0x51 && len(payload) == 3:
+				tempo := int(payload[0])<<16 | int(payload[1])<<8 | int(payload[2])
+				events = append(events, rawEvent{tick: tick, tempo: tempo, meta: true})
+			case kind == 0x2f:
+				// An end-of-track marker still extends the song length.
+				events = append(events, rawEvent{tick: tick, tempo: -1, meta: true})
+				return events, text, nil
+			case kind == 0x02:
+				text.copyright = strings.TrimSpace(string(payload))
+			case kind == 0x03:
+				text.name = strings.TrimSpace(string(payload))
+			}
+			continue
+		case status == 0xf0 || status == 0xf7:
+			length, err := readVarLen()
+			if err != nil {
+				return nil, text, err
+			}
+			if uint64(len(data)-pos) < length {
+				return nil, text, io.ErrUnexpectedEOF
+			}
+			pos += int(length)
+			continue
+		case status&0x80 != 0:
+			running = status
+		default:
+			if running == 0 {
+				return nil, text, fmt.Errorf("data byte %#x without running status", status)
+			}
+			// Running status: this byte is the first data byte.
+			pos--
+			status = running
+		}
+
+		channel := int(status & 0x0f)
+		data1, err := readByte()
+		if err != nil {
+			return nil, text, err
+		}
+		var data2 byte
+		switch status & 0xf0 {
+		case 0xc0, 0xd0:
+		default:
+			if data2, err = readByte(); err != nil {
+				return nil, text, err
+			}
+		}
+
+		ev := Event{Channel: channel, Data1: int(data1), Data2: int(data2)}
+		switch status & 0xf0 {
+		case 0x80:
+			ev.Kind = NoteOff
+		case 0x90:
+			ev.Kind = NoteOn
+			if data2 == 0 {
+				ev.Kind = NoteOff
+			}
+		case 0xb0:
+			ev.Kind = ControlChange
+		case 0xc0:
+			ev.Kind = ProgramChange
+		case 0xe0:
+			ev.Kind = PitchBend
+			ev.Data1 = int(data1) | int(data2)<<7
+			ev.Data2 = 0
+		default:
+			// Aftertouch and channel pressure do not affect the synth.
+			continue
+		}
+		events = append(events, rawEvent{tick: tick, event: ev})
+	}
+	return events, text, nil
+}
+
+// clock converts ticks to wall time across tempo changes.
+type clock struct {
+	ticksPerQuarter uint64
+	ticksPerSecond  float64
+	tempo           int
+	baseTick        uint64
+	baseTime        time.Duration
+}
+
+func newClock(division uint16) *clock {
+	c := &clock{tempo: defaultTempo}
+	if division&0x8000 != 0 {
+		// SMPTE timing: negative frames per second times ticks per frame.
+		fps := float64(-int8(division >> 8))
+		if fps == 29 {
+			fps = 29.97
+		}
+		c.ticksPerSecond = fps * float64(division&0xff)
+	} else {
+		c.ticksPerQuarter = uint64(max(division, 1))
+	}
+	return c
+}
+
+func (c *clock) at(tick uint64) time.Duration {
+	elapsed := tick - c.baseTick
+	if c.ticksPerSecond > 0 {
+		return c.baseTime + time.Duration(float64(elapsed)/c.ticksPerSecond*float64(time.Second))
+	}
+	micros := float64(elapsed) * float64(c.tempo) / float64(c.ticksPerQuarter)
+	return c.baseTime + time.Duration(micros*float64(time.Microsecond))
+}
+
+func (c *clock) setTempo(tick uint64, tempo int) {
+	if tempo <= 0 {
+		return
+	}
+	c.baseTime = c.at(tick)
+	c.baseTick = tick
+	c.tempo = tempo
+}
@@ -0,0 +1,131 @@
+package midi
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseAppliesTempoChangesAndRunningStatus(t *testing.T) {
+	data := []byte{
+		'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0, 96,
+		'M', 'T', 'r', 'k', 0, 0, 0, 29,
+		0x00, 0xff, 0x51, 0x03, 0x07, 0xa1, 0x20, // 500000us per quarter
+		0x00, 0x90, 60, 100, // note on
+		0x60, 60, 0, // running status note on with velocity 0 after one quarter
+		0x00, 0xff, 0x51, 0x03, 0x0f, 0x42, 0x40, // slow to 1000000us per quarter
+		0x60, 0x80, 62, 0, // note off one slow quarter later
+		0x00, 0xff, 0x2f, 0x00,
+	}
+
+	file, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(file.Events) != 3 {
+		t.Fatalf("event count = %d, want 3", len(file.Events))
+	}
+	if ev := file.Events[1]; ev.Kind != NoteOff || ev.Time != 500*time.Millisecond {
+		t.Fatalf("running status event = %+v, want note off at 500ms", ev)
+	}
+	if ev := file.Events[2]; ev.Data1 != 62 || ev.Time != 1500*time.Millisecond {
+		t.Fatalf("post-tempo event = %+v, want note 62 at 1.5s", ev)
+	}
+	if file.Length != 1500*time.Millisecond {
+		t.Fatalf("length = %v, want 1.5s", file.Length)
+	}
+}
+
+func TestParseBundledSongs(t *testing.T) {
+	for _, name := range []string{"flourish.mid", "onestop.mid", "town.mid"} {
+		f, err := os.Open("../../sounds/wav/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := Parse(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("parse %s: %v", name, err)
+		}
+
+		notes := 0
+		for _, ev := range file.Events {
+			if ev.Kind == NoteOn {
+				notes++
+			}
+		}
+		if notes == 0 || file.Length <= 0 {
+			t.Fatalf("%s: notes = %d, length = %v, want playable song", name, notes, file.Length)
+		}
+	}
+}
+
+func TestStreamerSeekMatchesContinuousPlayback(t *testing.T) {
+	f, err := os.Open("../../sounds/wav/town.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	file, err := Parse(f)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	const (
+		sampleRate = 22050
+		offset     = 3 * sampleRate
+		window     = 2048
+	)
+	continuous := NewStreamer(file, sampleRate)
+	skipped := make([][2]float64, offset)
+	if n, ok := continuous.Stream(skipped); !ok || n != offset {
+		t.Fatalf("stream to offset = (%d, %v), want (%d, true)", n, ok, offset)
+	}
+	want := make([][2]float64, window)
+	continuous.Stream(want)
+
+	seeked := NewStreamer(file, sampleRate)
+	if err := seeked.Seek(offset); err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+	got := make([][2]float64, window)
+	seeked.Stream(got)
+
+	var energy float64
+	for i := range want {
+		for c := 0; c < 2; c++ {
+			if math.Abs(got[i][c]-want[i][c]) > 1e-6 {
+				t.Fatalf("sample %d channel %d = %v, want %v", i, c, got[i][c], want[i][c])
+			}
+			energy += want[i][c] * want[i][c]
+		}
+	}
+	if energy == 0 {
+		t.Fatal("rendered window is silent, want audible synth output")
+	}
+}
+
+func TestStreamerStopsAtLength(t *testing.T) {
+	file := &File{
+		Events: []Event{
+			{Kind: NoteOn, Channel: PercussionChannel, Data1: 36, Data2: 127},
+			{Time: 100 * time.Millisecond, Kind: NoteOn, Data1: 60, Data2: 100},
+			{Time: 200 * time.Millisecond, Kind: NoteOff, Data1: 60},
+		},
+		Length: 200 * time.Millisecond,
+	}
+	s := NewStreamer(file, 1000)
+	if got, want := s.Len(), 1700; got != want {
+		t.Fatalf("len = %d, want %d including release tail", got, want)
+	}
+
+	buf := make([][2]float64, 4096)
+	if n, ok := s.Stream(buf); !ok || n != s.Len() {
+		t.Fatalf("stream = (%d, %v), want (%d, true)", n, ok, s.Len())
+	}
+	if n, ok := s.Stream(buf); ok || n != 0 {
+		t.Fatalf("stream at end = (%d, %v), want (0, false)", n, ok)
+	}
+}
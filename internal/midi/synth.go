@@ -0,0 +1,315 @@
+package midi
+
+import (
+	"math"
+	"time"
+
+	"github.com/gopxl/beep/v2"
+)
+
+const (
+	maxVoices      = 64
+	masterGain     = 0.3
+	bendRange      = 2.0 // semitones either side of center
+	releaseTail    = 1500 * time.Millisecond
+	drumSweepDecay = 0.04
+	drumAttack     = 0.001
+	envelopeFloor  = 7.0 // time constants until a voice is inaudible
+)
+
+type scheduledEvent struct {
+	at int
+	Event
+}
+
+type channelState struct {
+	program    int
+	volume     float64
+	expression float64
+	pan        float64
+	sustain    bool
+	bend       float64
+}
+
+func defaultChannel() channelState {
+	return channelState{volume: 100.0 / 127, expression: 1, pan: 0.5}
+}
+
+type voice struct {
+	channel   int
+	note      int
+	velocity  float64
+	start     int
+	released  int
+	sustained bool
+	phaseBase float64
+	phaseAt   int
+	step      float64
+	seed      uint64
+	isDrum    bool
+	melodic   instrument
+	drum      drum
+}
+
+// Streamer renders a parsed MIDI file with a small built-in General MIDI
+// synthesizer. Rendering is deterministic, so seeking replays the event list
+// up to the target and resumes exactly where uninterrupted playback would be.
+type Streamer struct {
+	sampleRate beep.SampleRate
+	events     []scheduledEvent
+	length     int
+	position   int
+	next       int
+	channels   [16]channelState
+	voices     []voice
+}
+
+func NewStreamer(file *File, sampleRate beep.SampleRate) *Streamer {
+	s := &Streamer{
+		sampleRate: sampleRate,
+		events:     make([]scheduledEvent, 0, len(file.Events)),
+		length:     sampleRate.N(file.Length + releaseTail),
+	}
+	for _, ev := range file.Events {
+		s.events = append(s.events, scheduledEvent{at: sampleRate.N(ev.Time), Event: ev})
+	}
+	s.reset()
+	return s
+}
+
+func (s *Streamer) reset() {
+	for i := range s.channels {
+		s.channels[i] = defaultChannel()
+	}
+	s.voices = s.voices[:0]
+	s.next = 0
+	s.position = 0
+}
+
+func (s *Streamer) Stream(samples [][2]float64) (int, bool) {
+	if s.position >= s.length {
+		return 0, false
+	}
+
+	n := min(len(samples), s.length-s.position)
+	for i := 0; i < n; i++ {
+		for s.next < len(s.events) && s.events[s.next].at <= s.position {
+			s.apply(s.events[s.next].Event)
+			s.next++
+		}
+		samples[i] = s.render()
+		s.position++
+	}
+	s.prune()
+	return n, true
+}
+
+func (s *Streamer) Err() error {
+	return nil
+}
+
+func (s *Streamer) Len() int {
+	return s.length
+}
+
+func (s *Streamer) Position() int {
+	return s.position
+}
+
+func (s *Streamer) Seek(p int) error {
+	p = max(0, min(p, s.length))
+	s.reset()
+	for s.next < len(s.events) && s.events[s.next].at < p {
+		s.position = s.events[s.next].at
+		s.apply(s.events[s.next].Event)
+		s.next++
+	}
+	s.position = p
+	s.prune()
+	return nil
+}
+
+func (s *Streamer) Close() error {
+	return nil
+}
+
+func (s *Streamer) apply(ev Event) {
+	ch := &s.channels[ev.Channel]
+	switch ev.Kind {
+	case NoteOn:
+		s.noteOn(ev.Channel, ev.Data1, ev.Data2)
+	case NoteOff:
+		s.noteOff(ev.Channel, ev.Data1)
+	case ProgramChange:
+		ch.program = ev.Data1
+	case PitchBend:
+		ch.bend = float64(ev.Data1-8192) / 8192 * bendRange
+		for i := range s.voices {
+			v := &s.voices[i]
+			if v.channel == ev.Channel && !v.isDrum {
+				// Rebase the phase so the waveform stays continuous.
+				v.phaseBase = v.phase(s.position)
+				v.phaseAt = s.position
+				v.step = noteFrequency(v.note, ch.bend) / float64(s.sampleRate)
+			}
+		}
+	case ControlChange:
+		s.controlChange(ev.Channel, ev.Data1, ev.Data2)
+	}
+}
+
+func (s *Streamer) noteOn(channel, note, velocity int) {
+	ch := s.channels[channel]
+	v := voice{
+		channel:  channel,
+		note:     note,
+		velocity: float64(velocity) / 127,
+		start:    s.position,
+		phaseAt:  s.position,
+		released: -1,
+		seed:     uint64(channel)<<56 ^ uint64(note)<<48 ^ uint64(s.position),
+	}
+	if channel == PercussionChannel {
+		v.isDrum = true
+		v.drum = percussionSound(note)
+	} else {
+		s.noteOff(channel, note)
+		v.melodic = melodicInstrument(ch.program)
+		v.step = noteFrequency(note, ch.bend) / float64(s.sampleRate)
+	}
+
+	if len(s.voices) >= maxVoices {
+		s.voices = append(s.voices[:0], s.voices[1:]...)
+	}
+	s.voices = append(s.voices, v)
+}
+
+func (s *Streamer) noteOff(channel, note int) {
+	sustain := s.channels[channel].sustain
+	for i := range s.voices {
+		v := &s.voices[i]
+		if v.channel != channel || v.note != note || v.isDrum || v.released >= 0 {
+			continue
+		}
+		if sustain {
+			v.sustained = true
+			continue
+		}
+		v.released = s.position
+	}
+}
+
+func (s *Streamer) controlChange(channel, controller, value int) {
+	ch := &s.channels[channel]
+	switch controller {
+	case 7:
+		ch.volume = float64(value) / 127
+	case 10:
+		ch.pan = float64(value) / 127
+	case 11:
+		ch.expression = float64(value) / 127
+	case 64:
+		ch.sustain = value >= 64
+		if !ch.sustain {
+			for i := range s.voices {
+				v := &s.voices[i]
+				if v.channel == channel && v.sustained && v.released < 0 {
+					v.released = s.position
+				}
+			}
+		}
+	case 120, 123:
+		for i := range s.voices {
+			v := &s.voices[i]
+			if v.channel == channel && v.released < 0 {
+				v.released = s.position
+			}
+		}
+	case 121:
+		program := ch.program
+		*ch = defaultChannel()
+		ch.program = program
+	}
+}
+
+func (s *Streamer) render() [2]float64 {
+	sr := float64(s.sampleRate)
+	var left, right float64
+	for i := range s.voices {
+		v := &s.voices[i]
+		ageSamples := s.position - v.start
+		age := float64(ageSamples) / sr
+
+		var sample float64
+		if v.isDrum {
+			sample = v.drum.sample(age, noiseAt(v.seed, ageSamples), noiseAt(v.seed, ageSamples-1)) * v.drum.gain
+		} else {
+			sample = oscillate(v.melodic.wave, v.phase(s.position), noiseAt(v.seed, ageSamples)) *
+				v.envelope(ageSamples, sr) * v.melodic.gain
+		}
+
+		ch := s.channels[v.channel]
+		sample *= v.velocity * ch.volume * ch.expression
+		left += sample * math.Cos(ch.pan*math.Pi/2)
+		right += sample * math.Sin(ch.pan*math.Pi/2)
+	}
+	return [2]float64{math.Tanh(left * masterGain), math.Tanh(right * masterGain)}
+}
+
+func (v voice) phase(position int) float64 {
+	return v.phaseBase + v.step*float64(position-v.phaseAt)
+}
+
+func (v voice) envelope(ageSamples int, sampleRate float64) float64 {
+	if v.released < 0 {
+		return v.melodic.level(float64(ageSamples) / sampleRate)
+	}
+	releaseAge := float64(v.released-v.start) / sampleRate
+	sinceRelease := float64(ageSamples)/sampleRate - releaseAge
+	return v.melodic.level(releaseAge) * math.Exp(-sinceRelease/v.melodic.release)
+}
+
+func (v voice) finished(position int, sampleRate float64) bool {
+	age := float64(position-v.start) / sampleRate
+	switch {
+	case v.isDrum:
+		return age > v.drum.decay*envelopeFloor
+	case v.released >= 0:
+		return float64(position-v.released)/sampleRate > v.melodic.release*envelopeFloor
+	default:
+		return v.melodic.sustain == 0 && age > v.melodic.attack+v.melodic.decay*envelopeFloor
+	}
+}
+
+func (s *Streamer) prune() {
+	sr := float64(s.sampleRate)
+	active := s.voices[:0]
+	for _, v := range s.voices {
+		if !v.finished(s.position, sr) {
+			active = append(active, v)
+		}
+	}
+	s.voices = active
+}
+
+func (in instrument) level(age float64) float64 {
+	if age < in.attack {
+		return age / in.attack
+	}
+	return in.sustain + (1-in.sustain)*math.Exp(-(age-in.attack)/in.decay)
+}
+
+func (d drum) sample(age, noise, previousNoise float64) float64 {
+	envelope := math.Exp(-age/d.decay) * min(1, age/drumAttack)
+	if d.highpass {
+		noise = (noise - previousNoise) / 2
+	}
+
+	var tone float64
+	if d.pitch > 0 {
+		// Integrated phase of an exponential pitch drop from pitch+sweep to pitch.
+		phase := d.pitch*age + d.sweep*drumSweepDecay*(1-math.Exp(-age/drumSweepDecay))
+		tone = math.Sin(2 * math.Pi * phase)
+	}
+	return ((1-d.noiseMix)*tone + d.noiseMix*noise) * envelope
+}
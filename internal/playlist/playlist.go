@@ -0,0 +1,223 @@
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kjloveless/tmp/internal/atomicfile"
+)
+
+var ErrUnsupported = errors.New("unsupported playlist format")
+
+// Entry is one playlist item. Title and Duration are empty or negative when
+// the playlist does not carry them.
+type Entry struct {
+	Path     string
+	Title    string
+	Duration time.Duration
+}
+
+var extensions = []string{".m3u", ".m3u8", ".pls"}
+
+func Extensions() []string {
+	return slices.Clone(extensions)
+}
+
+func Supported(path string) bool {
+	return slices.Contains(extensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// Load reads the playlist at path. Relative entries are resolved against the
+// playlist's directory.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8":
+		entries, err = ParseM3U(f)
+	case ".pls":
+		entries, err = ParsePLS(f)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range entries {
+		entries[i].Path = resolve(dir, entries[i].Path)
+	}
+	return entries, nil
+}
+
+// resolve turns a playlist location into a local path. file:// URLs are
+// unwrapped; other URLs are returned unchanged.
+func resolve(dir, location string) string {
+	if u, err := url.Parse(location); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		if u.Scheme != "file" {
+			return location
+		}
+		location = u.Path
+	}
+	location = filepath.FromSlash(location)
+	if filepath.IsAbs(location) {
+		return filepath.Clean(location)
+	}
+	return filepath.Join(dir, location)
+}
+
+// ParseM3U reads plain and extended M3U. #EXTINF lines annotate the entry
+// that follows them; other directives are ignored.
+func ParseM3U(r io.Reader) ([]Entry, error) {
+	var (
+		entries []Entry
+		info    = Entry{Duration: -1}
+	)
+	scanner := bufio.NewScanner(r)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info = parseExtinf(strings.TrimPrefix(line, "#EXTINF:"))
+		case strings.HasPrefix(line, "#"):
+		default:
+			info.Path = line
+			entries = append(entries, info)
+			info = Entry{Duration: -1}
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseExtinf reads "seconds[ attributes],title".
+func parseExtinf(value string) Entry {
+	entry := Entry{Duration: -1}
+	length, title, _ := strings.Cut(value, ",")
+	entry.Title = strings.TrimSpace(title)
+	if fields := strings.Fields(length); len(fields) > 0 {
+		entry.Duration = seconds(fields[0])
+	}
+	return entry
+}
+
+func seconds(value string) time.Duration {
+	s, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || s < 0 || math.IsInf(s, 0) || math.IsNaN(s) {
+		return -1
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// ParsePLS reads the [playlist] section of a PLS file, ordering entries by
+// their index.
+func ParsePLS(r io.Reader) ([]Entry, error) {
+	byIndex := make(map[int]*Entry)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "\ufeff")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		var field string
+		for _, prefix := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, prefix) {
+				field = prefix
+				break
+			}
+		}
+		if field == "" {
+			continue
+		}
+		index, err := strconv.Atoi(key[len(field):])
+		if err != nil {
+			continue
+		}
+		entry := byIndex[index]
+		if entry == nil {
+			entry = &Entry{Duration: -1}
+			byIndex[index] = entry
+		}
+		value = strings.TrimSpace(value)
+		switch field {
+		case "file":
+			entry.Path = value
+		case "title":
+			entry.Title = value
+		case "length":
+			entry.Duration = seconds(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(byIndex))
+	for index, entry := range byIndex {
+		if entry.Path != "" {
+			indexes = append(indexes, index)
+		}
+	}
+	slices.Sort(indexes)
+	entries := make([]Entry, 0, len(indexes))
+	for _, index := range indexes {
+		entries = append(entries, *byIndex[index])
+	}
+	return entries, nil
+}
+
+// WriteM3U8 writes an extended M3U playlist. Unknown durations are written
+// as -1, as the format expects.
+func WriteM3U8(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	for _, entry := range entries {
+		length := -1
+		if entry.Duration >= 0 {
+			length = int(entry.Duration.Round(time.Second) / time.Second)
+		}
+		title := entry.Title
+		if title == "" {
+			title = filepath.Base(entry.Path)
+		}
+		// A line break in a title would end the directive early.
+		title = strings.NewReplacer("\r", " ", "\n", " ").Replace(title)
+		fmt.Fprintf(bw, "#EXTINF:%d,%s\n", length, title)
+		fmt.Fprintln(bw, entry.Path)
+	}
+	return bw.Flush()
+}
+
+// Save writes entries to path as M3U8. The file is written beside path and
+// renamed into place, so a failed save leaves any existing playlist intact.
+func Save(path string, entries []Entry) error {
+	var buf bytes.Buffer
+	if err := WriteM3U8(&buf, entries); err != nil {
+		return err
+	}
+	return atomicfile.Write(path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,95 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadM3UResolvesRelativePathsAndReadsExtinf(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mix.m3u8")
+	data := "\ufeff#EXTM3U\n" +
+		"#EXTINF:123,Artist – Song\n" +
+		"album/01 song.mp3\n" +
+		"\n" +
+		"# a comment\n" +
+		"/music/other.flac\n" +
+		"file:///music/with%20space.ogg\n" +
+		"http://example.com/stream.mp3\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Path: filepath.Join(dir, "album", "01 song.mp3"), Title: "Artist – Song", Duration: 123 * time.Second},
+		{Path: filepath.FromSlash("/music/other.flac"), Duration: -1},
+		{Path: filepath.FromSlash("/music/with space.ogg"), Duration: -1},
+		{Path: "http://example.com/stream.mp3", Duration: -1},
+	}
+	if !slices.Equal(entries, want) {
+		t.Fatalf("entries = %+v, want %+v", entries, want)
+	}
+}
+
+func TestParsePLSOrdersEntriesByIndex(t *testing.T) {
+	data := "[playlist]\n" +
+		"File2=b.mp3\n" +
+		"Title2=B\n" +
+		"File1=a.mp3\n" +
+		"Length1=61.5\n" +
+		"Title3=no file\n" +
+		"NumberOfEntries=2\n" +
+		"Version=2\n"
+	entries, err := ParsePLS(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Path: "a.mp3", Duration: 61500 * time.Millisecond},
+		{Path: "b.mp3", Title: "B", Duration: -1},
+	}
+	if !slices.Equal(entries, want) {
+		t.Fatalf("entries = %+v, want %+v", entries, want)
+	}
+}
+
+func TestWriteM3U8RoundTrips(t *testing.T) {
+	entries := []Entry{
+		{Path: "/music/a.mp3", Title: "Artist – A", Duration: 201400 * time.Millisecond},
+		{Path: "/music/b.mp3", Duration: -1},
+	}
+	var b strings.Builder
+	if err := WriteM3U8(&b, entries); err != nil {
+		t.Fatal(err)
+	}
+	want := "#EXTM3U\n#EXTINF:201,Artist – A\n/music/a.mp3\n#EXTINF:-1,b.mp3\n/music/b.mp3\n"
+	if b.String() != want {
+		t.Fatalf("WriteM3U8() = %q, want %q", b.String(), want)
+	}
+
+	parsed, err := ParseM3U(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[0].Duration != 201*time.Second || parsed[1].Title != "b.mp3" {
+		t.Fatalf("parsed = %+v", parsed)
+	}
+}
+
+func TestLoadRejectsUnknownExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("Load() should reject a non-playlist file")
+	}
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kjloveless/tmp/internal/atomicfile"
+)
+
+// State is what the player restores on launch. Position is how far into
+// Playing playback had got.
+type State struct {
+	Directory string        `json:"directory,omitempty"`
+	Volume    int           `json:"volume"`
+	Muted     bool          `json:"muted,omitempty"`
+	Loop      string        `json:"loop,omitempty"`
+	Playing   string        `json:"playing,omitempty"`
+	Position  time.Duration `json:"position,omitempty"`
+	Queue     []Entry       `json:"queue,omitempty"`
+}
+
+type Entry struct {
+	Path  string `json:"path"`
+	Title string `json:"title,omitempty"`
+}
+
+// DefaultPath is the session file under $XDG_STATE_HOME, which defaults to
+// ~/.local/state.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if !filepath.IsAbs(dir) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "tmp", "session.json"), nil
+}
+
+// Load reads the state saved at path. ok is false when nothing has been
+// saved yet.
+func Load(path string) (state State, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return State{}, false, nil
+	}
+	if err != nil {
+		return State{}, false, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, false, err
+	}
+	return state, true, nil
+}
+
+// Save writes state to path, replacing the previous file only once the new
+// one is complete so that a crash mid-write keeps the last session.
+func Save(path string, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return atomicfile.Write(path, data, 0o600)
+}
@@ -0,0 +1,68 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "session.json")
+	want := State{
+		Directory: "/music",
+		Volume:    80,
+		Muted:     true,
+		Loop:      "queue",
+		Playing:   "/music/a.mp3",
+		Position:  83 * time.Second,
+		Queue:     []Entry{{Path: "/music/b.mp3", Title: "B"}},
+	}
+	if err := Save(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := Load(path)
+	if err != nil || !ok {
+		t.Fatalf("Load() = %v, %v", ok, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Load() = %+v, want %+v", got, want)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("state directory holds %d files, want no leftover temp files", len(entries))
+	}
+}
+
+func TestLoadWithoutSavedStateIsNotAnError(t *testing.T) {
+	_, ok, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if ok || err != nil {
+		t.Fatalf("Load() = %v, %v; want nothing saved and no error", ok, err)
+	}
+}
+
+func TestDefaultPathFollowsXDGStateHome(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+	path, err := DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("/xdg/state", "tmp", "session.json"); path != want {
+		t.Fatalf("DefaultPath() = %q, want %q", path, want)
+	}
+
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/someone")
+	path, err = DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("/home/someone", ".local", "state", "tmp", "session.json"); path != want {
+		t.Fatalf("DefaultPath() = %q, want %q", path, want)
+	}
+}
@@ -0,0 +1,242 @@
+package theme
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SpectrumLevels is how many colors a theme gives the spectrum, from quiet
+// to loud. Levels below the first are drawn in Muted.
+const SpectrumLevels = 5
+
+// Theme holds every color the player draws with, as "#rrggbb", "#rgb" or
+// an ANSI color number.
+type Theme struct {
+	Name string `toml:"-"`
+	// Border frames unfocused panels and colors titles.
+	Border string `toml:"border"`
+	// Focus frames the focused panel and highlights keys.
+	Focus string `toml:"focus"`
+	// Accent frames the sound map and colors section headings.
+	Accent string `toml:"accent"`
+	// Info frames the help and status panels.
+	Info          string   `toml:"info"`
+	Text          string   `toml:"text"`
+	Muted         string   `toml:"muted"`
+	Grid          string   `toml:"grid"`
+	ProgressStart string   `toml:"progress_start"`
+	ProgressEnd   string   `toml:"progress_end"`
+	Spectrum      []string `toml:"spectrum"`
+}
+
+var builtins = []Theme{
+	{
+		Name:          "dark",
+		Border:        "#89dceb",
+		Focus:         "#f5c2e7",
+		Accent:        "#f9e2af",
+		Info:          "#94e2d5",
+		Text:          "#a6adc8",
+		Muted:         "#6c7086",
+		Grid:          "#585b70",
+		ProgressStart: "#ff7ccb",
+		ProgressEnd:   "#fdff8c",
+		Spectrum:      []string{"#74c7ec", "#a6e3a1", "#f9e2af", "#fab387", "#f38ba8"},
+	},
+	{
+		Name:          "light",
+		Border:        "#04a5e5",
+		Focus:         "#ea76cb",
+		Accent:        "#df8e1d",
+		Info:          "#179299",
+		Text:          "#5c5f77",
+		Muted:         "#9ca0b0",
+		Grid:          "#acb0be",
+		ProgressStart: "#ea76cb",
+		ProgressEnd:   "#df8e1d",
+		Spectrum:      []string{"#209fb5", "#40a02b", "#df8e1d", "#fe640b", "#d20f39"},
+	},
+	{
+		Name:          "high-contrast",
+		Border:        "#ffffff",
+		Focus:         "#ffff00",
+		Accent:        "#00ffff",
+		Info:          "#ffffff",
+		Text:          "#ffffff",
+		Muted:         "#a0a0a0",
+		Grid:          "#808080",
+		ProgressStart: "#00ff00",
+		ProgressEnd:   "#ffff00",
+		Spectrum:      []string{"#00ffff", "#00ff00", "#ffff00", "#ff8000", "#ff0000"},
+	},
+	{
+		// Shades of grey only, for terminals or eyes where hue carries no
+		// meaning.
+		Name:          "monochrome",
+		Border:        "#b0b0b0",
+		Focus:         "#ffffff",
+		Accent:        "#d0d0d0",
+		Info:          "#b0b0b0",
+		Text:          "#d0d0d0",
+		Muted:         "#6c6c6c",
+		Grid:          "#4e4e4e",
+		ProgressStart: "#8a8a8a",
+		ProgressEnd:   "#ffffff",
+		Spectrum:      []string{"#808080", "#9e9e9e", "#bcbcbc", "#dadada", "#ffffff"},
+	},
+}
+
+// Names lists the built-in themes; the first is the default.
+func Names() []string {
+	names := make([]string, len(builtins))
+	for i, t := range builtins {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func Builtin(name string) (Theme, bool) {
+	for _, t := range builtins {
+		if t.Name == name {
+			return t.clone(), true
+		}
+	}
+	return Theme{}, false
+}
+
+func Default() Theme {
+	return builtins[0].clone()
+}
+
+func (t Theme) clone() Theme {
+	t.Spectrum = slices.Clone(t.Spectrum)
+	return t
+}
+
+// Resolve returns the built-in theme called name, or loads name as a theme
+// file when it looks like a path.
+func Resolve(name string) (Theme, error) {
+	if t, ok := Builtin(name); ok {
+		return t, nil
+	}
+	if strings.ContainsRune(name, filepath.Separator) || filepath.Ext(name) == ".toml" {
+		return Load(name)
+	}
+	return Theme{}, fmt.Errorf("unknown theme %q (built in: %s)", name, strings.Join(Names(), ", "))
+}
+
+// Load reads a theme file. Colors it leaves out come from the built-in theme
+// named by its base key, dark by default.
+func Load(path string) (Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, err
+	}
+	var file struct {
+		Base string `toml:"base"`
+		Theme
+	}
+	meta, err := toml.Decode(string(data), &file)
+	if err != nil {
+		return Theme{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return Theme{}, fmt.Errorf("%s: unknown color %s", path, undecoded[0])
+	}
+
+	base := Default()
+	if file.Base != "" {
+		var ok bool
+		if base, ok = Builtin(file.Base); !ok {
+			return Theme{}, fmt.Errorf("%s: base: unknown theme %q", path, file.Base)
+		}
+	}
+	t := base.overlay(file.Theme)
+	t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if err := t.Validate(); err != nil {
+		return Theme{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// overlay takes every color set in o.
+func (t Theme) overlay(o Theme) Theme {
+	for _, field := range []struct{ dst, src *string }{
+		{&t.Border, &o.Border},
+		{&t.Focus, &o.Focus},
+		{&t.Accent, &o.Accent},
+		{&t.Info, &o.Info},
+		{&t.Text, &o.Text},
+		{&t.Muted, &o.Muted},
+		{&t.Grid, &o.Grid},
+		{&t.ProgressStart, &o.ProgressStart},
+		{&t.ProgressEnd, &o.ProgressEnd},
+	} {
+		if *field.src != "" {
+			*field.dst = *field.src
+		}
+	}
+	if o.Spectrum != nil {
+		t.Spectrum = slices.Clone(o.Spectrum)
+	}
+	return t
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func validColor(c string) bool {
+	if hexColor.MatchString(c) {
+		return true
+	}
+	n, err := strconv.Atoi(c)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// Validate reports the first color that is missing or malformed, by its key
+// in a theme file.
+func (t Theme) Validate() error {
+	for _, field := range []struct{ name, value string }{
+		{"border", t.Border},
+		{"focus", t.Focus},
+		{"accent", t.Accent},
+		{"info", t.Info},
+		{"text", t.Text},
+		{"muted", t.Muted},
+		{"grid", t.Grid},
+		{"progress_start", t.ProgressStart},
+		{"progress_end", t.ProgressEnd},
+	} {
+		if !validColor(field.value) {
+			return fmt.Errorf("%s: %q is not a color; use #rrggbb, #rgb or 0-255", field.name, field.value)
+		}
+	}
+	if len(t.Spectrum) != SpectrumLevels {
+		return fmt.Errorf("spectrum: want %d colors from quiet to loud, got %d", SpectrumLevels, len(t.Spectrum))
+	}
+	for _, c := range t.Spectrum {
+		if !validColor(c) {
+			return fmt.Errorf("spectrum: %q is not a color; use #rrggbb, #rgb or 0-255", c)
+		}
+	}
+	return nil
+}
+
+var active = Default()
+
+// Active is the theme everything is drawn with.
+func Active() Theme {
+	return active
+}
+
+// Use makes t the active theme. It is meant to be called once at startup,
+// before anything is drawn.
+func Use(t Theme) {
+	active = t.clone()
+}
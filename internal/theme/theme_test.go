@@ -0,0 +1,109 @@
+package theme
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTheme(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mine.toml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuiltinsAreValid(t *testing.T) {
+	want := []string{"dark", "light", "high-contrast", "monochrome"}
+	if got := Names(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Names() = %v, want %v", got, want)
+	}
+	for _, name := range want {
+		th, ok := Builtin(name)
+		if !ok {
+			t.Fatalf("Builtin(%q) missing", name)
+		}
+		if err := th.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if Default().Name != "dark" {
+		t.Fatalf("Default() = %q, want dark", Default().Name)
+	}
+}
+
+func TestLoadOverlaysBase(t *testing.T) {
+	path := writeTheme(t, `
+base = "light"
+focus = "#ff0000"
+spectrum = ["1", "2", "3", "4", "5"]
+`)
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	light, _ := Builtin("light")
+	if got.Name != "mine" || got.Focus != "#ff0000" || got.Border != light.Border {
+		t.Fatalf("Load() = %+v, want light with a red focus color", got)
+	}
+	if strings.Join(got.Spectrum, ",") != "1,2,3,4,5" {
+		t.Fatalf("Spectrum = %v", got.Spectrum)
+	}
+}
+
+func TestLoadDefaultsToDark(t *testing.T) {
+	got, err := Load(writeTheme(t, "grid = \"#123\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Grid != "#123" || got.Border != Default().Border {
+		t.Fatalf("Load() = %+v, want dark with a new grid color", got)
+	}
+}
+
+func TestLoadReportsBadThemes(t *testing.T) {
+	for _, tc := range []struct {
+		data, want string
+	}{
+		{"border = \"blue\"\n", `border: "blue" is not a color`},
+		{"muted = \"256\"\n", `muted: "256" is not a color`},
+		{"spectrum = [\"#fff\"]\n", "spectrum: want 5 colors from quiet to loud, got 1"},
+		{"spectrum = [\"#fff\", \"#fff\", \"#fff\", \"#fff\", \"#ggg\"]\n", `spectrum: "#ggg" is not a color`},
+		{"base = \"solarized\"\n", `base: unknown theme "solarized"`},
+		{"backgound = \"#000\"\n", "unknown color backgound"},
+	} {
+		_, err := Load(writeTheme(t, tc.data))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load(%q) error = %v, want it to mention %q", tc.data, err, tc.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if th, err := Resolve("monochrome"); err != nil || th.Name != "monochrome" {
+		t.Fatalf("Resolve(monochrome) = %q, %v", th.Name, err)
+	}
+	path := writeTheme(t, "focus = \"#fff\"\n")
+	if th, err := Resolve(path); err != nil || th.Focus != "#fff" {
+		t.Fatalf("Resolve(%q) = %+v, %v", path, th, err)
+	}
+	if _, err := Resolve("solarized"); err == nil || !strings.Contains(err.Error(), "dark, light, high-contrast, monochrome") {
+		t.Fatalf("Resolve(solarized) error = %v, want the built-in names listed", err)
+	}
+}
+
+func TestUseDoesNotShareSpectrum(t *testing.T) {
+	t.Cleanup(func() { Use(Default()) })
+	th := Default()
+	Use(th)
+	th.Spectrum[0] = "#000"
+	if Active().Spectrum[0] == "#000" {
+		t.Fatal("changing a theme after Use changed the active theme")
+	}
+	if Default().Spectrum[0] == "#000" {
+		t.Fatal("changing a copy changed the built-in theme")
+	}
+}
@@ -1,26 +1,47 @@
 package track
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kjloveless/tmp/internal/control"
+	"github.com/kjloveless/tmp/internal/metadata"
+	"github.com/kjloveless/tmp/internal/theme"
 
 	"github.com/gopxl/beep/v2"
 	"github.com/gopxl/beep/v2/speaker"
 
 	"charm.land/bubbles/v2/progress"
 	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
 )
 
+// Repeat is an A-B segment in track time. Playback only repeats once both
+// points are set.
+type Repeat struct {
+	A, B       time.Duration
+	HasA, HasB bool
+}
+
+func (r Repeat) Active() bool {
+	return r.HasA && r.HasB
+}
+
 type Track struct {
 	Control  control.Control
 	Format   *beep.Format
 	Title    string
+	Tags     metadata.Tags
+	Repeat   Repeat
 	length   time.Duration
 	progress progress.Model
 }
 
+// Position is the playback position within the track, in track time.
 func (t Track) Position() time.Duration {
 	speaker.Lock()
 	if t.Control.Source != nil {
@@ -36,12 +57,17 @@ func (t Track) Duration() time.Duration {
 	return t.length
 }
 
+// Remaining is the wall-clock time left at the current speed.
 func (t Track) Remaining() time.Duration {
-	remaining := t.length - t.Position()
+	return t.remainingFrom(t.Position())
+}
+
+func (t Track) remainingFrom(position time.Duration) time.Duration {
+	remaining := t.length - position
 	if remaining < 0 {
 		return 0
 	}
-	return remaining
+	return time.Duration(float64(remaining) / t.Control.Rate())
 }
 
 func (t Track) Percent() float64 {
@@ -69,6 +95,50 @@ func displayDuration(d time.Duration) string {
 	return fmt.Sprintf("%d:%02d.%03d", minutes, seconds, milliseconds)
 }
 
+// ParseDuration reads a timestamp in the format displayDuration prints, such
+// as 1:23:45.500 or 3:07, or a plain number of seconds like 95.5.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty timestamp")
+	}
+	fields := strings.Split(s, ":")
+	if len(fields) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	minutes := 0
+	for i, field := range fields[:len(fields)-1] {
+		n, err := strconv.Atoi(field)
+		// Minutes after an hour field must be below 60, as displayed.
+		if err != nil || !isDigits(field) || (i > 0 && n >= 60) {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		minutes = minutes*60 + n
+	}
+	secondsField := fields[len(fields)-1]
+	whole, fraction, _ := strings.Cut(secondsField, ".")
+	seconds, err := strconv.ParseFloat(secondsField, 64)
+	if err != nil || !isDigits(whole) || (fraction != "" && !isDigits(fraction)) ||
+		(len(fields) > 1 && seconds >= 60) {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	millis := math.Round(seconds * 1000)
+	return time.Duration(minutes)*time.Minute + time.Duration(millis)*time.Millisecond, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func (t Track) String() string {
 	position := t.Position()
 	if position < 0 {
@@ -78,10 +148,7 @@ func (t Track) String() string {
 		position = t.length
 	}
 
-	remaining := t.length - position
-	if remaining < 0 {
-		remaining = 0
-	}
+	remaining := t.remainingFrom(position)
 	percent := 0.0
 	if t.length > 0 {
 		percent = position.Seconds() / t.length.Seconds()
@@ -89,20 +156,50 @@ func (t Track) String() string {
 
 	return fmt.Sprintf(
 		"%s %s / %s (-%s)",
-		t.progress.ViewAs(percent),
+		t.markRepeat(t.progress.ViewAs(percent)),
 		displayDuration(position),
 		displayDuration(t.length),
 		displayDuration(remaining))
 }
 
+// BarWidth is the width of the progress bar at the start of String.
+func (t Track) BarWidth() int {
+	return t.progress.Width()
+}
+
+// markRepeat overlays the A and B points on the rendered progress bar.
+func (t Track) markRepeat(bar string) string {
+	width := t.progress.Width()
+	if t.length <= 0 || width <= 0 {
+		return bar
+	}
+	mark := func(bar string, at time.Duration, label string) string {
+		column := int(at.Seconds() / t.length.Seconds() * float64(width-1))
+		column = max(0, min(column, width-1))
+		return ansi.Cut(bar, 0, column) + repeatMarkerStyle().Render(label) + ansi.Cut(bar, column+1, width)
+	}
+	if t.Repeat.HasA {
+		bar = mark(bar, t.Repeat.A, "A")
+	}
+	if t.Repeat.HasB {
+		bar = mark(bar, t.Repeat.B, "B")
+	}
+	return bar
+}
+
+func repeatMarkerStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(theme.Active().Border))
+}
+
 func New(
 	streamer beep.StreamSeekCloser,
 	format *beep.Format,
 	title string,
 	length time.Duration,
 ) Track {
+	palette := theme.Active()
 	prog := progress.New(
-		progress.WithColors(lipgloss.Color("#ff7ccb"), lipgloss.Color("#fdff8c")),
+		progress.WithColors(lipgloss.Color(palette.ProgressStart), lipgloss.Color(palette.ProgressEnd)),
 		progress.WithScaled(true),
 		progress.WithSpringOptions(6.0, .5),
 	)
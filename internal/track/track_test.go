@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/charmbracelet/x/ansi"
 	"github.com/gopxl/beep/v2"
 )
 
@@ -48,3 +49,65 @@ func TestStringUsesSingleMillisecondSnapshotForTimeDisplay(t *testing.T) {
 		t.Fatalf("track string = %q, want consistent millisecond display", got)
 	}
 }
+
+func TestRemainingAccountsForPlaybackSpeed(t *testing.T) {
+	source := &testStream{len: 20, position: 4}
+	format := beep.Format{SampleRate: 10, NumChannels: 2, Precision: 2}
+	track := New(source, &format, "short.mp3", 2*time.Second)
+	track.Control.Speed = 2
+
+	if got := track.Position(); got != 400*time.Millisecond {
+		t.Fatalf("position = %s, want track time 400ms", got)
+	}
+	if got := track.Remaining(); got != 800*time.Millisecond {
+		t.Fatalf("remaining = %s, want 800ms at 2x", got)
+	}
+	if got := track.String(); !strings.Contains(got, "0:00.400 / 0:02.000 (-0:00.800)") {
+		t.Fatalf("track string = %q, want remaining time at 2x", got)
+	}
+}
+
+func TestStringMarksRepeatPointsOnProgressBar(t *testing.T) {
+	source := &testStream{len: 20, position: 4}
+	format := beep.Format{SampleRate: 10, NumChannels: 2, Precision: 2}
+	track := New(source, &format, "short.mp3", 2*time.Second)
+	track.Repeat = Repeat{A: 500 * time.Millisecond, B: 1500 * time.Millisecond, HasA: true, HasB: true}
+
+	plain := []rune(ansi.Strip(New(source, &format, "short.mp3", 2*time.Second).String()))
+	got := []rune(ansi.Strip(track.String()))
+	if len(got) != len(plain) {
+		t.Fatalf("marked string = %q, want the same width as %q", string(got), string(plain))
+	}
+	width := track.progress.Width()
+	if a, b := got[(width-1)/4], got[3*(width-1)/4]; a != 'A' || b != 'B' {
+		t.Fatalf("progress bar = %q, want A at a quarter and B at three quarters", string(got[:width]))
+	}
+}
+
+func TestParseDurationReadsDisplayedTimestamps(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+	}{
+		{"1:23:45.500", time.Hour + 23*time.Minute + 45*time.Second + 500*time.Millisecond},
+		{"3:07", 3*time.Minute + 7*time.Second},
+		{"0:00.000", 0},
+		{"95.5", 95*time.Second + 500*time.Millisecond},
+		{" 12 ", 12 * time.Second},
+	} {
+		got, err := ParseDuration(tc.in)
+		if err != nil || got != tc.want {
+			t.Fatalf("ParseDuration(%q) = %s, %v; want %s", tc.in, got, err, tc.want)
+		}
+		if tc.want > 0 {
+			if again, err := ParseDuration(displayDuration(got)); err != nil || again != got {
+				t.Fatalf("ParseDuration(%q) = %s, %v; want round trip of %s", displayDuration(got), again, err, got)
+			}
+		}
+	}
+	for _, in := range []string{"", "1:2:3:4", "1:60:00", "1:75", "-5", "1e3", "a:00", "1:.5"} {
+		if got, err := ParseDuration(in); err == nil {
+			t.Fatalf("ParseDuration(%q) = %s, want an error", in, got)
+		}
+	}
+}